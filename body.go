@@ -0,0 +1,18 @@
+package rest
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// Maximum number of bytes read from a discarded response body so its
+// connection can be reused.
+const maxDrainBytes = 64 << 10
+
+// discard reads what's left of raw, up to maxDrainBytes, and closes body, which
+// is raw or a reader on top of it. Draining the body allows the connection to
+// be reused, larger leftovers are cheaper to drop along with the connection.
+func discard(raw io.Reader, body io.Closer) {
+	io.CopyN(ioutil.Discard, raw, maxDrainBytes)
+	body.Close()
+}
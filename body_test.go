@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// trackingTransport counts the response bodies that are still open.
+type trackingTransport struct {
+	http.RoundTripper
+	open int32
+}
+
+func (self *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := self.RoundTripper.RoundTrip(req)
+	if res != nil {
+		atomic.AddInt32(&self.open, 1)
+		res.Body = &trackedBody{ReadCloser: res.Body, open: &self.open}
+	}
+	return res, err
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	open   *int32
+	closed bool
+}
+
+func (self *trackedBody) Close() error {
+	if !self.closed {
+		self.closed = true
+		atomic.AddInt32(self.open, -1)
+	}
+	return self.ReadCloser.Close()
+}
+
+func TestBodyLifecycle(t *testing.T) {
+	var err error
+	var conns int32
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name": `))
+			w.Write(bytes.Repeat([]byte(" "), 4096))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`not found`))
+		case "/compress":
+			w.Header().Set("Content-Encoding", "compress")
+			w.Write([]byte(`...`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name": "gopher"}`))
+			// Trailing data a streaming decoder stops before.
+			w.Write(bytes.Repeat([]byte("\n"), 4096))
+		}
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &trackingTransport{RoundTripper: newTransport()}
+	client.Transport = transport
+
+	var v struct {
+		Name string `json:"name"`
+	}
+	var res Response
+	var buf []byte
+	var s string
+	var b *bytes.Buffer
+	var m map[string]interface{}
+
+	for _, test := range []struct {
+		path string
+		dst  interface{}
+		fail bool
+	}{
+		{"/", &v, false},
+		{"/", &res, false},
+		{"/", &buf, false},
+		{"/", &s, false},
+		{"/", &b, false},
+		{"/", &m, false},
+		{"/", nil, false},
+		{"/", v, true},
+		{"/invalid", &v, true},
+		{"/missing", &v, true},
+		{"/compress", &buf, true},
+	} {
+		ctx := context.Background()
+		if test.path == "/missing" {
+			ctx = WithErrorDestination(ctx, &s)
+		}
+
+		err = client.R().Into(test.dst).Get(ctx, test.path)
+
+		if (err != nil) != test.fail {
+			t.Fatalf("%s %T: unexpected error %v.", test.path, test.dst, err)
+		}
+
+		if open := atomic.LoadInt32(&transport.open); open != 0 {
+			t.Fatalf("%s %T: %d bodies left open.", test.path, test.dst, open)
+		}
+	}
+
+	if conns != 1 {
+		t.Fatalf("Expecting a single connection, got %d.", conns)
+	}
+
+	// io.ReadCloser destinations own the body.
+	var body io.ReadCloser
+	if err = client.R().Into(&body).Get(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if open := atomic.LoadInt32(&transport.open); open != 1 {
+		t.Fatalf("Expecting the body to be open.")
+	}
+
+	body.Close()
+
+	if open := atomic.LoadInt32(&transport.open); open != 0 {
+		t.Fatalf("Expecting the body to be closed.")
+	}
+}
@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Charset converts text between UTF-8 and some other character encoding. The
+// Reader and Writer methods of the decoders and encoders from
+// golang.org/x/text/encoding can be used as they are:
+//
+//	rest.RegisterCharset("shift_jis", rest.Charset{
+//		NewDecoder: japanese.ShiftJIS.NewDecoder().Reader,
+//		NewEncoder: japanese.ShiftJIS.NewEncoder().Writer,
+//	})
+type Charset struct {
+	// Returns a reader that converts the text read from r into UTF-8.
+	NewDecoder func(r io.Reader) io.Reader
+	// Returns a writer that converts UTF-8 text into the charset before
+	// writing it to w. If the writer is also an io.Closer it's closed once
+	// everything was written.
+	NewEncoder func(w io.Writer) io.Writer
+}
+
+var (
+	charsetsMu sync.RWMutex
+	charsets   = map[string]Charset{}
+)
+
+func init() {
+	identity := Charset{
+		NewDecoder: func(r io.Reader) io.Reader { return r },
+		NewEncoder: func(w io.Writer) io.Writer { return w },
+	}
+	latin1 := Charset{
+		NewDecoder: func(r io.Reader) io.Reader { return &singleByteReader{r: bufio.NewReader(r)} },
+		NewEncoder: func(w io.Writer) io.Writer { return &singleByteWriter{w: w, max: 0xff} },
+	}
+	ascii := Charset{
+		NewDecoder: identity.NewDecoder,
+		NewEncoder: func(w io.Writer) io.Writer { return &singleByteWriter{w: w, max: 0x7f} },
+	}
+
+	for _, name := range []string{"utf-8", "utf8"} {
+		charsets[name] = identity
+	}
+	for _, name := range []string{"iso-8859-1", "iso_8859-1", "latin1", "l1"} {
+		charsets[name] = latin1
+	}
+	for _, name := range []string{"us-ascii", "ascii"} {
+		charsets[name] = ascii
+	}
+}
+
+// RegisterCharset makes a character encoding available under the given name,
+// names are case insensitive. UTF-8, US-ASCII and ISO-8859-1 are available by
+// default.
+func RegisterCharset(name string, charset Charset) {
+	charsetsMu.Lock()
+	defer charsetsMu.Unlock()
+
+	charsets[strings.ToLower(name)] = charset
+}
+
+func lookupCharset(name string) (Charset, error) {
+	charsetsMu.RLock()
+	defer charsetsMu.RUnlock()
+
+	if charset, ok := charsets[strings.ToLower(name)]; ok {
+		return charset, nil
+	}
+
+	return Charset{}, fmt.Errorf(ErrUnknownCharset.Error(), name)
+}
+
+// charsetReader converts input from the given charset into UTF-8, its
+// signature matches the CharsetReader field of xml.Decoder.
+func charsetReader(name string, input io.Reader) (io.Reader, error) {
+	charset, err := lookupCharset(name)
+	if err != nil {
+		return nil, err
+	}
+	return charset.NewDecoder(input), nil
+}
+
+// singleByteReader decodes ISO-8859-1 text into UTF-8.
+type singleByteReader struct {
+	r       *bufio.Reader
+	pending []byte
+}
+
+func (self *singleByteReader) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		if len(self.pending) > 0 {
+			c := copy(p[n:], self.pending)
+			self.pending = self.pending[c:]
+			n += c
+			continue
+		}
+
+		b, err := self.r.ReadByte()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+
+		var buf [utf8.UTFMax]byte
+		self.pending = buf[:utf8.EncodeRune(buf[:], rune(b))]
+	}
+
+	return n, nil
+}
+
+// singleByteWriter encodes UTF-8 text into a charset whose code points match
+// the first max+1 Unicode code points, like ISO-8859-1 and US-ASCII.
+type singleByteWriter struct {
+	w       io.Writer
+	max     rune
+	partial []byte
+}
+
+func (self *singleByteWriter) Write(p []byte) (int, error) {
+	buf := append(self.partial, p...)
+	out := make([]byte, 0, len(buf))
+
+	for len(buf) > 0 {
+		if !utf8.FullRune(buf) {
+			break
+		}
+
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size == 1 || r > self.max {
+			return 0, fmt.Errorf(ErrCouldNotEncodeCharset.Error(), r)
+		}
+
+		out = append(out, byte(r))
+		buf = buf[size:]
+	}
+
+	self.partial = append([]byte(nil), buf...)
+
+	if _, err := self.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close reports text that ended in the middle of a UTF-8 sequence.
+func (self *singleByteWriter) Close() error {
+	if len(self.partial) > 0 {
+		return fmt.Errorf(ErrCouldNotEncodeCharset.Error(), utf8.RuneError)
+	}
+	return nil
+}
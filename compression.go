@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Bodies up to this length are compressed in memory before being sent, so the
+// request gets a Content-Length. Longer ones are compressed while they're sent.
+const maxCompressedInMemory = 8 << 20
+
+// Compression compresses request bodies with a content coding, the
+// Content-Encoding header of the request is set accordingly.
+type Compression struct {
+	// Content coding to use, like "gzip" or "zstd". An empty Encoding leaves
+	// bodies as they are, "identity" does too but also disables the client's
+	// compression when given for a single call.
+	Encoding string
+	// Bodies known to be shorter than this are sent as they are. Bodies of
+	// unknown length are always compressed.
+	MinSize int64
+}
+
+// override returns other if it sets an encoding, self otherwise.
+func (self Compression) override(other Compression) Compression {
+	if other.Encoding != "" {
+		return other
+	}
+	return self
+}
+
+// WithCompression returns a copy of ctx that makes the request compress its
+// body as given by compression instead of following the client's Compression.
+func WithCompression(ctx context.Context, compression Compression) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.compression = compression
+	})
+}
+
+// compress returns body compressed as the call requires. Bodies that already
+// have a content coding are left alone.
+func (self *Client) compress(ctx context.Context, header http.Header, body *requestBody) (*requestBody, error) {
+	compression := self.Compression.override(optionsFrom(ctx).compression)
+
+	name := strings.ToLower(compression.Encoding)
+
+	switch {
+	case name == "" || name == "identity":
+		return body, nil
+	case body.contentEncoding != "" || header.Get("Content-Encoding") != "" || self.Header.Get("Content-Encoding") != "":
+		return body, nil
+	case body.size >= 0 && body.size < compression.MinSize:
+		return body, nil
+	}
+
+	encoding, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if encoding.NewWriter == nil {
+		return nil, fmt.Errorf(ErrUnsupportedEncoding.Error(), name)
+	}
+
+	if body.size < 0 || body.size > maxCompressedInMemory {
+		return newCompressedStreamBody(body, name, encoding), nil
+	}
+
+	return newCompressedBody(body, name, encoding)
+}
+
+// newCompressedBody compresses body in memory, so the length of the result is
+// known.
+func newCompressedBody(body *requestBody, name string, encoding Encoding) (*requestBody, error) {
+	var buf bytes.Buffer
+
+	r, err := body.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	w, err := encoding.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	compressed := newBytesBody(body.contentType, buf.Bytes())
+	compressed.contentEncoding = name
+
+	return compressed, nil
+}
+
+// newCompressedStreamBody compresses body while it's being sent, its length is
+// unknown so the request is sent chunked.
+func newCompressedStreamBody(body *requestBody, name string, encoding Encoding) *requestBody {
+	return &requestBody{
+		contentType:     body.contentType,
+		contentEncoding: name,
+		size:            -1,
+		oneShot:         body.oneShot,
+		open: func() (io.ReadCloser, error) {
+			r, err := body.open()
+			if err != nil {
+				return nil, err
+			}
+
+			pr, pw := io.Pipe()
+
+			go func() {
+				defer r.Close()
+
+				w, err := encoding.NewWriter(pw)
+				if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+
+				// Copying stops as soon as the transport closes the reader.
+				if _, err = io.Copy(w, r); err != nil {
+					w.Close()
+					pw.CloseWithError(err)
+					return
+				}
+
+				pw.CloseWithError(w.Close())
+			}()
+
+			return pr, nil
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	var err error
+
+	type received struct {
+		Encoding string
+		Length   string
+		Body     string
+	}
+
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+
+		length := ""
+		if r.ContentLength >= 0 {
+			length = strconv.FormatInt(r.ContentLength, 10)
+			if r.ContentLength != int64(len(raw)) {
+				t.Errorf("Content-Length %d for a %d bytes body.", r.ContentLength, len(raw))
+			}
+		}
+
+		encoding := r.Header.Get("Content-Encoding")
+
+		if encoding != "" && encoding != "identity" {
+			coding, err := lookupEncoding(encoding)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			dr, err := coding.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if raw, err = io.ReadAll(dr); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+
+		if r.URL.Path == "/flaky" {
+			if attempts++; attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(received{encoding, length, string(raw)})
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := strings.Repeat("gopher ", 100)
+
+	client.Compression = Compression{Encoding: "gzip", MinSize: 100}
+
+	var res received
+
+	// Below the threshold.
+	if err = client.PostRaw(&res, "/", []byte("small")); err != nil {
+		t.Fatal(err)
+	}
+	if res.Encoding != "" || res.Body != "small" {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	if err = client.PostRaw(&res, "/", []byte(large)); err != nil {
+		t.Fatal(err)
+	}
+	if res.Encoding != "gzip" || res.Body != large || res.Length == "" || res.Length == strconv.Itoa(len(large)) {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	// Per request settings.
+	if err = client.R().Body("text/plain", []byte(large)).Compression(Compression{Encoding: "zstd"}).Into(&res).Post(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if res.Encoding != "zstd" || res.Body != large {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	if err = client.R().Body("text/plain", []byte(large)).Compression(Compression{Encoding: "identity"}).Into(&res).Post(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if res.Encoding != "" || res.Body != large {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	// Bodies that are already encoded are left alone.
+	if err = client.R().Body("text/plain", []byte("plain")).Header("Content-Encoding", "identity").Into(&res).Post(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if res.Body != "plain" {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	// Streamed bodies are compressed on the fly.
+	if err = client.R().StreamJSON([]string{"a", "b"}).Into(&res).Post(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if res.Encoding != "gzip" || res.Length != "" || res.Body != "[\"a\",\"b\"]\n" {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	// Compressed bodies are sent again on retries.
+	client.RetryPolicy = &Backoff{MinDelay: 1, Methods: []string{"POST"}}
+
+	if err = client.R().Body("text/plain", []byte(large)).Into(&res).Post(context.Background(), "/flaky"); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || res.Encoding != "gzip" || res.Body != large {
+		t.Fatalf("Unexpected request %#v after %d attempts.", res, attempts)
+	}
+
+	if err = client.R().Body("text/plain", []byte(large)).Compression(Compression{Encoding: "compress"}).Post(context.Background(), "/"); err == nil {
+		t.Fatalf("Expecting an error for an unknown coding.")
+	}
+}
@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Decoder converts a response body into a destination value.
+type Decoder interface {
+	Decode(r io.Reader, dst interface{}) error
+}
+
+// ContentTypeDecoder is implemented by decoders that need the parameters of
+// the Content-Type header, like its charset.
+type ContentTypeDecoder interface {
+	Decoder
+	DecodeContentType(r io.Reader, contentType string, dst interface{}) error
+}
+
+// DecoderFunc allows ordinary functions to be used as Decoders.
+type DecoderFunc func(r io.Reader, dst interface{}) error
+
+// Decode calls fn(r, dst).
+func (fn DecoderFunc) Decode(r io.Reader, dst interface{}) error {
+	return fn(r, dst)
+}
+
+// JSONDecoder is the Decoder used by default for JSON responses.
+type JSONDecoder struct {
+	// Decode numbers into interface{} values as json.Number instead of
+	// float64.
+	UseNumber bool
+	// Fail when an object has keys that don't match any field of the
+	// destination struct.
+	DisallowUnknownFields bool
+}
+
+// Decode implements Decoder. Top level arrays going into slices are decoded
+// while being read, one element at a time, so only the current element needs
+// to be held in memory besides the result. Any other value, including slices
+// with their own UnmarshalJSON or UnmarshalText methods, is read whole before
+// being decoded, like json.Unmarshal does.
+func (self JSONDecoder) Decode(r io.Reader, dst interface{}) error {
+	var err error
+
+	dec := json.NewDecoder(r)
+
+	if self.UseNumber {
+		dec.UseNumber()
+	}
+
+	if self.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	rv := reflect.ValueOf(dst)
+
+	if decodesAsArray(rv) {
+		err = decodeJSONArray(dec, rv.Elem())
+	} else {
+		err = dec.Decode(dst)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Just like json.Unmarshal, anything but whitespace after the value is an
+	// error.
+	if _, err = dec.Token(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodesAsArray reports whether rv is a pointer to a slice that can be
+// decoded element by element, which is not the case for []byte arrays or
+// slices that unmarshal themselves.
+func decodesAsArray(rv reflect.Value) bool {
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return false
+	}
+
+	t := rv.Elem().Type()
+
+	if t.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+
+	for _, t := range []reflect.Type{rv.Type(), t} {
+		if t.Implements(jsonUnmarshalerType) || t.Implements(textUnmarshalerType) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decodeJSONArray decodes a JSON array into the slice dst, element by
+// element.
+func decodeJSONArray(dec *json.Decoder, dst reflect.Value) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if token == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return &json.UnmarshalTypeError{Value: fmt.Sprintf("%v", token), Type: dst.Type(), Offset: dec.InputOffset()}
+	}
+
+	// An addressable slice can grow in place, reflect.Append and Slice
+	// allocate on every call.
+	out := reflect.New(dst.Type()).Elem()
+	if dst.Cap() > 0 {
+		out.Set(dst.Slice(0, 0))
+	} else {
+		out.Set(reflect.MakeSlice(dst.Type(), 0, 0))
+	}
+
+	for i := 0; dec.More(); i++ {
+		if i == out.Cap() {
+			out.Grow(i + 4)
+		}
+		out.SetLen(i + 1)
+
+		elem := out.Index(i)
+		elem.SetZero()
+
+		if err = dec.Decode(elem.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+
+	// Closing bracket.
+	if _, err = dec.Token(); err != nil {
+		return err
+	}
+
+	dst.Set(out)
+
+	return nil
+}
+
+// Decoders used when a client doesn't register its own.
+var defaultDecoders = map[string]Decoder{
+	"application/json": JSONDecoder{},
+	"*/*+json":         JSONDecoder{},
+	"application/xml":  XMLDecoder{},
+	"text/xml":         XMLDecoder{},
+	"*/*+xml":          XMLDecoder{},
+}
+
+// RegisterDecoder sets the decoder for responses of the given media range.
+// Besides full media types, like "application/json", the range can be
+// "type/*", "*/*", or use a structured syntax suffix like "*/*+json" or
+// "application/*+xml". When looking up a decoder the most specific range wins:
+// first the exact media type, then the suffix ranges, then "type/*" and
+// finally "*/*". A nil decoder disables decoding for that range, responses
+// are then only converted into strings and []byte arrays.
+func (self *Client) RegisterDecoder(mediaRange string, decoder Decoder) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.decoders == nil {
+		self.decoders = map[string]Decoder{}
+	}
+
+	self.decoders[strings.ToLower(mediaRange)] = decoder
+}
+
+// decoder returns the decoder for the given media type, or nil if there is
+// none.
+func (self *Client) decoder(mt string) Decoder {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, mediaRange := range mediaRanges(mt) {
+		if decoder, ok := self.decoders[mediaRange]; ok {
+			return decoder
+		}
+		if decoder, ok := defaultDecoders[mediaRange]; ok {
+			return decoder
+		}
+	}
+
+	return nil
+}
+
+// mediaRanges returns the media ranges that match the given media type, from
+// the most specific to the least specific one.
+func mediaRanges(mt string) []string {
+	if mt == "" {
+		return nil
+	}
+
+	ranges := []string{mt}
+
+	slash := strings.Index(mt, "/")
+	if slash < 0 {
+		return append(ranges, "*/*")
+	}
+
+	major, minor := mt[:slash], mt[slash+1:]
+
+	if plus := strings.LastIndex(minor, "+"); plus >= 0 {
+		suffix := minor[plus:]
+		ranges = append(ranges, major+"/*"+suffix, "*/*"+suffix)
+	}
+
+	return append(ranges, major+"/*", "*/*")
+}
+
+// decode runs decoder over r, passing the content type along if the decoder
+// wants it.
+func decode(decoder Decoder, r io.Reader, contentType string, dst interface{}) error {
+	if d, ok := decoder.(ContentTypeDecoder); ok {
+		return d.DecodeContentType(r, contentType, dst)
+	}
+	return decoder.Decode(r, dst)
+}
@@ -0,0 +1,286 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMediaRanges(t *testing.T) {
+	got := mediaRanges("application/vnd.api+json")
+	expected := []string{"application/vnd.api+json", "application/*+json", "*/*+json", "application/*", "*/*"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expecting %v, got %v.", expected, got)
+	}
+}
+
+func TestDecoderRegistry(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vnd":
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.Write([]byte(`[{"id": 1}, {"id": 2}]`))
+		case "/csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Write([]byte("a,b,c"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": 12345678901234567890, "extra": true}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Structured syntax suffixes are decoded as JSON, into any type.
+	var items []struct {
+		ID int `json:"id"`
+	}
+
+	if err = client.Get(&items, "/vnd", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 || items[1].ID != 2 {
+		t.Fatalf("Unexpected items %v.", items)
+	}
+
+	// Custom media types.
+	client.RegisterDecoder("text/*", DecoderFunc(func(r io.Reader, dst interface{}) error {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		*(dst.(*[]string)) = strings.Split(string(buf), ",")
+		return nil
+	}))
+
+	var fields []string
+	if err = client.Get(&fields, "/csv", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(fields, "|") != "a|b|c" {
+		t.Fatalf("Unexpected fields %v.", fields)
+	}
+
+	// Strings still get the raw body.
+	var raw string
+	if err = client.Get(&raw, "/csv", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if raw != "a,b,c" {
+		t.Fatalf("Unexpected body %q.", raw)
+	}
+
+	// Overriding the JSON decoder.
+	client.RegisterDecoder("application/json", JSONDecoder{UseNumber: true})
+
+	var m map[string]interface{}
+	if err = client.Get(&m, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["id"] != json.Number("12345678901234567890") {
+		t.Fatalf("Expecting a json.Number, got %T.", m["id"])
+	}
+
+	client.RegisterDecoder("application/json", JSONDecoder{DisallowUnknownFields: true})
+
+	var s struct {
+		ID json.Number `json:"id"`
+	}
+	if err = client.Get(&s, "/", nil); err == nil {
+		t.Fatalf("Expecting unknown field error.")
+	}
+}
+
+// csvIDs is sent as a string of comma separated numbers.
+type csvIDs []int
+
+func (self *csvIDs) UnmarshalJSON(buf []byte) error {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return err
+	}
+	return self.UnmarshalText([]byte(s))
+}
+
+func (self *csvIDs) UnmarshalText(buf []byte) error {
+	*self = nil
+	for _, field := range strings.Split(string(buf), ",") {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return err
+		}
+		*self = append(*self, id)
+	}
+	return nil
+}
+
+// textIDs only implements encoding.TextUnmarshaler.
+type textIDs []int
+
+func (self *textIDs) UnmarshalText(buf []byte) error {
+	return (*csvIDs)(self).UnmarshalText(buf)
+}
+
+func TestJSONDecoderUnmarshalers(t *testing.T) {
+	var err error
+
+	var ids csvIDs
+	if err = (JSONDecoder{}).Decode(strings.NewReader(`"1,2,3"`), &ids); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Fatalf("Unexpected ids %v.", ids)
+	}
+
+	var text textIDs
+	if err = (JSONDecoder{}).Decode(strings.NewReader(`"4,5"`), &text); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(text) != "[4 5]" {
+		t.Fatalf("Unexpected ids %v.", text)
+	}
+
+	// Same as json.Unmarshal, through a response.
+	var expected csvIDs
+	if err = json.Unmarshal([]byte(`"6,7"`), &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	ids = nil
+	if err = new(Client).handleResponse(&ids, benchmarkResponse([]byte(`"6,7"`)), callOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint(expected) {
+		t.Fatalf("Expecting %v, got %v.", expected, ids)
+	}
+}
+
+func TestJSONDecoderArrays(t *testing.T) {
+	var err error
+
+	type record struct {
+		ID int `json:"id"`
+	}
+
+	records := make([]record, 0, 10)
+
+	if err = (JSONDecoder{}).Decode(strings.NewReader(` [{"id": 1}, {"id": 2}] `), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[1].ID != 2 {
+		t.Fatalf("Unexpected records %v.", records)
+	}
+
+	if err = (JSONDecoder{}).Decode(strings.NewReader(`null`), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	if records != nil {
+		t.Fatalf("Expecting nil slice, got %v.", records)
+	}
+
+	if err = (JSONDecoder{}).Decode(strings.NewReader(`[]`), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	if records == nil || len(records) != 0 {
+		t.Fatalf("Expecting empty slice, got %#v.", records)
+	}
+
+	if err = (JSONDecoder{}).Decode(strings.NewReader(`{"id": 1}`), &records); err == nil {
+		t.Fatalf("Expecting type error.")
+	}
+
+	var m map[string]interface{}
+	if err = (JSONDecoder{}).Decode(strings.NewReader(`{"id": 1} {"id": 2}`), &m); err != ErrTrailingData {
+		t.Fatalf("Expecting ErrTrailingData, got %v.", err)
+	}
+}
+
+type benchmarkRecord struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Email   string   `json:"email"`
+	Tags    []string `json:"tags"`
+	Balance float64  `json:"balance"`
+}
+
+func benchmarkExport(n int) []byte {
+	records := make([]benchmarkRecord, n)
+	for i := range records {
+		records[i] = benchmarkRecord{i, "Gopher", "gopher@example.com", []string{"a", "b"}, 3.14}
+	}
+	buf, _ := json.Marshal(records)
+	return buf
+}
+
+func benchmarkResponse(buf []byte) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(buf)),
+	}
+}
+
+// BenchmarkDecodeJSONBuffered measures the former approach of reading the
+// whole body before unmarshaling it, through the same response handling as
+// BenchmarkDecodeJSONStreaming.
+func BenchmarkDecodeJSONBuffered(b *testing.B) {
+	buf := benchmarkExport(20000)
+
+	client := new(Client)
+	client.RegisterDecoder("application/json", DecoderFunc(func(r io.Reader, dst interface{}) error {
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, dst)
+	}))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var records []benchmarkRecord
+		if err := client.handleResponse(&records, benchmarkResponse(buf), callOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeJSONStreaming(b *testing.B) {
+	buf := benchmarkExport(20000)
+	client := new(Client)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var records []benchmarkRecord
+		if err := client.handleResponse(&records, benchmarkResponse(buf), callOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
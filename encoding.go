@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoding decompresses and compresses a HTTP content coding, as named by the
+// Content-Encoding header.
+type Encoding struct {
+	// Returns a reader that decompresses what's read from r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// Returns a writer that compresses what's written to it into w, it's
+	// closed once everything was written. Optional, it's only needed to
+	// compress request bodies.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	encodingsMu   sync.RWMutex
+	encodings     = map[string]Encoding{}
+	encodingNames []string
+)
+
+func init() {
+	RegisterEncoding("gzip", Encoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	})
+	RegisterEncoding("deflate", Encoding{
+		NewReader: newDeflateReader,
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+	})
+	RegisterEncoding("br", Encoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(brotli.NewReader(r)), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return brotli.NewWriter(w), nil
+		},
+	})
+	RegisterEncoding("zstd", Encoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		},
+	})
+}
+
+// RegisterEncoding makes a content coding available under the given name,
+// names are case insensitive. Registered codings are advertised in the
+// Accept-Encoding header of requests that don't set one. gzip, deflate, br
+// and zstd are available by default, registering a zero Encoding removes one.
+func RegisterEncoding(name string, encoding Encoding) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	name = strings.ToLower(name)
+
+	if encoding.NewReader == nil {
+		delete(encodings, name)
+		for i := range encodingNames {
+			if encodingNames[i] == name {
+				encodingNames = append(encodingNames[:i:i], encodingNames[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	if _, ok := encodings[name]; !ok {
+		encodingNames = append(encodingNames, name)
+	}
+	encodings[name] = encoding
+}
+
+func lookupEncoding(name string) (Encoding, error) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+
+	name = strings.ToLower(name)
+	if name == "x-gzip" {
+		name = "gzip"
+	}
+
+	if encoding, ok := encodings[name]; ok {
+		return encoding, nil
+	}
+
+	return Encoding{}, fmt.Errorf(ErrUnsupportedEncoding.Error(), name)
+}
+
+// acceptEncoding returns the value of the Accept-Encoding header that
+// advertises the registered content codings.
+func acceptEncoding() string {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+
+	return strings.Join(encodingNames, ", ")
+}
+
+// contentCodings returns the content codings of a response in the order they
+// were applied.
+func contentCodings(header http.Header) []string {
+	var codings []string
+
+	for _, value := range header["Content-Encoding"] {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.TrimSpace(coding)
+			if coding != "" && !strings.EqualFold(coding, "identity") {
+				codings = append(codings, coding)
+			}
+		}
+	}
+
+	return codings
+}
+
+// newDeflateReader decompresses the "deflate" coding, which should be zlib
+// data but is sent as a raw deflate stream by some servers.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+// decodedBody undoes the content codings of a response body. Decompressors are
+// created on the first read, so empty bodies are just empty.
+type decodedBody struct {
+	body    io.ReadCloser
+	codings []Encoding
+	r       io.Reader
+	closers []io.Closer
+	err     error
+}
+
+func (self *decodedBody) Read(p []byte) (int, error) {
+	if self.r == nil && self.err == nil {
+		self.err = self.open()
+	}
+	if self.err != nil {
+		return 0, self.err
+	}
+	return self.r.Read(p)
+}
+
+func (self *decodedBody) open() error {
+	br := bufio.NewReader(self.body)
+
+	if _, err := br.Peek(1); err != nil {
+		return err
+	}
+
+	var r io.Reader = br
+
+	// Codings are listed in the order they were applied.
+	for i := len(self.codings) - 1; i >= 0; i-- {
+		rc, err := self.codings[i].NewReader(r)
+		if err != nil {
+			return err
+		}
+		self.closers = append(self.closers, rc)
+		r = rc
+	}
+
+	self.r = r
+	return nil
+}
+
+func (self *decodedBody) Close() error {
+	for i := len(self.closers) - 1; i >= 0; i-- {
+		self.closers[i].Close()
+	}
+	return self.body.Close()
+}
@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestContentEncoding(t *testing.T) {
+	var err error
+
+	writers := map[string]func(io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"deflate": func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+		"raw-deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+		"br": func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriter(w)
+		},
+		"zstd": func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		},
+	}
+
+	const payload = `{"name": "gopher"}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept-Encoding"); accept != "gzip, deflate, br, zstd" {
+			t.Errorf("Unexpected Accept-Encoding %q.", accept)
+		}
+
+		codings := r.URL.Query()["coding"]
+
+		var buf bytes.Buffer
+		buf.WriteString(payload)
+
+		for _, coding := range codings {
+			var out bytes.Buffer
+			if fn, ok := writers[coding]; ok {
+				cw := fn(&out)
+				cw.Write(buf.Bytes())
+				cw.Close()
+			}
+			buf = out
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", strings.Replace(strings.Join(codings, ", "), "raw-", "", -1))
+
+		if r.Method != "HEAD" {
+			w.Write(buf.Bytes())
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, codings := range [][]string{
+		{"gzip"},
+		{"deflate"},
+		{"raw-deflate"},
+		{"br"},
+		{"zstd"},
+		{"gzip", "br"},
+		{"zstd", "gzip", "deflate"},
+	} {
+		var v struct {
+			Name string `json:"name"`
+		}
+		var res Response
+
+		if err = client.R().Query("coding", codings...).Into(&v).Get(context.Background(), "/"); err != nil {
+			t.Fatalf("%v: %v", codings, err)
+		}
+
+		if v.Name != "gopher" {
+			t.Fatalf("%v: unexpected value %#v.", codings, v)
+		}
+
+		if err = client.R().Query("coding", codings...).Into(&res).Get(context.Background(), "/"); err != nil {
+			t.Fatalf("%v: %v", codings, err)
+		}
+
+		if string(res.Body) != payload || res.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%v: unexpected response %q %v.", codings, res.Body, res.Header)
+		}
+
+		// Empty bodies are not decompressed.
+		if err = client.R().Query("coding", codings...).Into(&res).Head(context.Background(), "/"); err != nil {
+			t.Fatalf("%v: %v", codings, err)
+		}
+	}
+
+	var buf []byte
+	if err = client.R().Query("coding", "compress").Into(&buf).Get(context.Background(), "/"); err == nil || !strings.Contains(err.Error(), `"compress"`) {
+		t.Fatalf("Expecting an unsupported encoding error, got %v.", err)
+	}
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	encodingsMu.Lock()
+	saved, savedNames := encodings["br"], append([]string(nil), encodingNames...)
+	encodingsMu.Unlock()
+
+	defer func() {
+		encodingsMu.Lock()
+		encodings["br"], encodingNames = saved, savedNames
+		encodingsMu.Unlock()
+	}()
+
+	RegisterEncoding("br", Encoding{})
+
+	if accept := acceptEncoding(); accept != "gzip, deflate, zstd" {
+		t.Fatalf("Unexpected Accept-Encoding %q.", accept)
+	}
+
+	if _, err := lookupEncoding("br"); err == nil {
+		t.Fatalf("Expecting br to be removed.")
+	}
+
+	if _, err := lookupEncoding("X-GZIP"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,8 @@ package rest
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
 )
 
 var (
@@ -20,4 +22,85 @@ var (
 	// ErrDestinationNotAPointer is returned when attemping to provide a
 	// destination that is not a pointer.
 	ErrDestinationNotAPointer = errors.New(`Destination is not a pointer.`)
+
+	// ErrTrailingData is returned when a response body has more data after the
+	// value it was decoded from.
+	ErrTrailingData = errors.New(`Unexpected data after the response value.`)
+
+	// ErrUnknownCharset is returned when a character encoding that was not
+	// registered with RegisterCharset is needed.
+	ErrUnknownCharset = errors.New(`Unknown charset %q.`)
+
+	// ErrCouldNotEncodeCharset is returned when text can't be represented in
+	// the requested character encoding.
+	ErrCouldNotEncodeCharset = errors.New(`Could not encode character %q.`)
+
+	// ErrBodyNotReplayable is returned when a request body that can only be
+	// read once is needed again.
+	ErrBodyNotReplayable = errors.New(`Request body can't be sent again.`)
+
+	// ErrTLSConfigNotApplicable is returned when a client's TLSConfig can't be
+	// applied to the round tripper requests are sent through.
+	ErrTLSConfigNotApplicable = errors.New(`TLSConfig can only be applied to a nil Transport or a *http.Transport.`)
+
+	// ErrUnsupportedEncoding is returned when a response uses a content coding
+	// that was not registered with RegisterEncoding.
+	ErrUnsupportedEncoding = errors.New(`Unsupported content encoding %q.`)
+
+	// ErrResponseTooLarge is returned when a response body is larger than the
+	// limit set by MaxResponseBytes, or inflates more than MaxCompressionRatio
+	// allows.
+	ErrResponseTooLarge = errors.New(`Response body is too large.`)
+
+	// ErrUnsupportedMultipart is returned when building a multipart message of
+	// a subtype that is not supported.
+	ErrUnsupportedMultipart = errors.New(`Unsupported multipart message %q.`)
+
+	// ErrMissingBoundary is returned when a multipart response has no
+	// boundary.
+	ErrMissingBoundary = errors.New(`Multipart response without a boundary.`)
+
+	// ErrNotEventStream is returned when subscribing to an endpoint that
+	// doesn't respond with a text/event-stream.
+	ErrNotEventStream = errors.New(`Expecting a text/event-stream response, got %q.`)
 )
+
+// StatusError is returned when a response status is rejected by the client's
+// StatusPolicy. Use errors.As to retrieve it.
+type StatusError struct {
+	// Method and URL of the request that got this response.
+	Method string
+	URL    string
+
+	Status     string
+	StatusCode int
+	Header     http.Header
+	// Raw response body.
+	Body []byte
+	// Value the body was decoded into, when an error destination was
+	// provided.
+	Value interface{}
+	// Problem document of application/problem+json responses, decoded
+	// whether or not an error destination was provided.
+	Problem *ProblemDetails
+}
+
+func (self *StatusError) Error() string {
+	if self.Method == "" {
+		return fmt.Sprintf(`Unexpected response status %q.`, self.Status)
+	}
+	return fmt.Sprintf(`Unexpected response status %q from %s %s.`, self.Status, self.Method, self.URL)
+}
+
+// Unwrap returns the decoded error value, if it implements the error
+// interface, and the problem document, if any.
+func (self *StatusError) Unwrap() []error {
+	var errs []error
+	if err, ok := self.Value.(error); ok {
+		errs = append(errs, err)
+	}
+	if self.Problem != nil && self.Problem != self.Value {
+		errs = append(errs, self.Problem)
+	}
+	return errs
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+const jsonContentType = `application/json; charset=utf-8`
+
+// newJSONBody returns a body with the JSON encoding of v.
+func newJSONBody(v interface{}) (*requestBody, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return newBytesBody(jsonContentType, buf), nil
+}
+
+// newJSONStreamBody returns a body that encodes v while it's being sent,
+// without holding the whole encoding in memory. Its length is unknown, so the
+// request is sent chunked.
+func newJSONStreamBody(v interface{}) *requestBody {
+	return &requestBody{
+		contentType: jsonContentType,
+		size:        -1,
+		open: func() (io.ReadCloser, error) {
+			r, w := io.Pipe()
+			go func() {
+				// Encoding stops as soon as the transport closes the
+				// reader.
+				w.CloseWithError(json.NewEncoder(w).Encode(v))
+			}()
+			return r, nil
+		},
+	}
+}
+
+// PostJSON performs a HTTP POST request with the JSON encoding of body and,
+// when complete, attempts to convert the response body into the datatype
+// given by dst (a pointer to a struct, map or []byte array).
+func (self *Client) PostJSON(dst interface{}, path string, body interface{}) error {
+	return self.PostJSONContext(context.Background(), dst, path, body)
+}
+
+// PostJSONContext is like PostJSON but the request is bound to the given
+// context.
+func (self *Client) PostJSONContext(ctx context.Context, dst interface{}, path string, body interface{}) error {
+	return self.R().JSON(body).Into(dst).Post(ctx, path)
+}
+
+// PutJSON performs a HTTP PUT request with the JSON encoding of body and, when
+// complete, attempts to convert the response body into the datatype given by
+// dst (a pointer to a struct, map or []byte array).
+func (self *Client) PutJSON(dst interface{}, path string, body interface{}) error {
+	return self.PutJSONContext(context.Background(), dst, path, body)
+}
+
+// PutJSONContext is like PutJSON but the request is bound to the given
+// context.
+func (self *Client) PutJSONContext(ctx context.Context, dst interface{}, path string, body interface{}) error {
+	return self.R().JSON(body).Into(dst).Put(ctx, path)
+}
+
+// PatchJSON performs a HTTP PATCH request with the JSON encoding of body and,
+// when complete, attempts to convert the response body into the datatype
+// given by dst (a pointer to a struct, map or []byte array).
+func (self *Client) PatchJSON(dst interface{}, path string, body interface{}) error {
+	return self.PatchJSONContext(context.Background(), dst, path, body)
+}
+
+// PatchJSONContext is like PatchJSON but the request is bound to the given
+// context.
+func (self *Client) PatchJSONContext(ctx context.Context, dst interface{}, path string, body interface{}) error {
+	return self.R().JSON(body).Into(dst).Patch(ctx, path)
+}
+
+// PostJSON performs a HTTP POST request with the JSON encoding of body using
+// the default client.
+func PostJSON(dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PostJSON(dest, uri, body)
+}
+
+// PostJSONContext is like PostJSON but the request is bound to the given
+// context.
+func PostJSONContext(ctx context.Context, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PostJSONContext(ctx, dest, uri, body)
+}
+
+// PutJSON performs a HTTP PUT request with the JSON encoding of body using the
+// default client.
+func PutJSON(dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PutJSON(dest, uri, body)
+}
+
+// PutJSONContext is like PutJSON but the request is bound to the given
+// context.
+func PutJSONContext(ctx context.Context, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PutJSONContext(ctx, dest, uri, body)
+}
+
+// PatchJSON performs a HTTP PATCH request with the JSON encoding of body using
+// the default client.
+func PatchJSON(dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PatchJSON(dest, uri, body)
+}
+
+// PatchJSONContext is like PatchJSON but the request is bound to the given
+// context.
+func PatchJSONContext(ctx context.Context, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PatchJSONContext(ctx, dest, uri, body)
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONBody(t *testing.T) {
+	var err error
+
+	type thing struct {
+		Name string `json:"name"`
+		Size int    `json:"size"`
+	}
+
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in thing
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		attempts++
+		if r.URL.Path == "/flaky" && attempts < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"method":         r.Method,
+			"content_type":   r.Header.Get("Content-Type"),
+			"content_length": r.ContentLength,
+			"thing":          in,
+		})
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		Method        string `json:"method"`
+		ContentType   string `json:"content_type"`
+		ContentLength int64  `json:"content_length"`
+		Thing         thing  `json:"thing"`
+	}
+
+	for _, method := range []string{"POST", "PUT", "PATCH"} {
+		var res result
+
+		in := thing{"gopher", 3}
+
+		switch method {
+		case "POST":
+			err = client.PostJSON(&res, "/", in)
+		case "PUT":
+			err = client.PutJSON(&res, "/", in)
+		case "PATCH":
+			err = client.PatchJSON(&res, "/", in)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Method != method || res.Thing != in || res.ContentType != jsonContentType {
+			t.Fatalf("Unexpected response %#v.", res)
+		}
+
+		if res.ContentLength <= 0 {
+			t.Fatalf("Expecting a Content-Length.")
+		}
+	}
+
+	if client.Header.Get("Content-Type") != "" {
+		t.Fatalf("Client headers were modified.")
+	}
+
+	// Streamed bodies are sent chunked and encoded again on retries.
+	client.StreamJSON = true
+	attempts = 0
+	client.RetryPolicy = &Backoff{MinDelay: time.Millisecond}
+
+	var res result
+	if err = client.PutJSON(&res, "/flaky", thing{"streamed", 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Thing.Name != "streamed" || res.ContentLength != -1 || attempts != 2 {
+		t.Fatalf("Unexpected response %#v after %d attempts.", res, attempts)
+	}
+
+	// Encoding errors are reported.
+	if err = client.PostJSON(&res, "/", func() {}); err == nil || !strings.Contains(err.Error(), "json") {
+		t.Fatalf("Expecting encoding error, got %v.", err)
+	}
+
+}
@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"context"
+	"io"
+)
+
+// ratioGuardMinBytes is how much has to be decompressed before the
+// compression ratio of a response is checked, small bodies can have very high
+// ratios.
+const ratioGuardMinBytes = 1 << 20
+
+// WithMaxResponseBytes returns a copy of ctx that makes the request use n as
+// the limit for the size of the response body instead of the client's
+// MaxResponseBytes. A negative n removes the client's limit.
+func WithMaxResponseBytes(ctx context.Context, n int64) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.maxResponseBytes = n
+	})
+}
+
+// maxResponseBytes returns the limit for the size of a response body in a call
+// with the given options, zero if there's no limit.
+func (self *Client) maxResponseBytes(opts callOptions) int64 {
+	n := self.MaxResponseBytes
+	if opts.maxResponseBytes != 0 {
+		n = opts.maxResponseBytes
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// countingBody counts the bytes read from a body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (self *countingBody) Read(p []byte) (int, error) {
+	n, err := self.ReadCloser.Read(p)
+	self.n += int64(n)
+	return n, err
+}
+
+// limitedBody fails with ErrResponseTooLarge once more than max bytes were
+// read, or when what was read is more than ratio times larger than what was
+// received.
+type limitedBody struct {
+	io.ReadCloser
+	max        int64
+	ratio      float64
+	compressed *countingBody
+	n          int64
+	err        error
+}
+
+// limitBody returns body limited as given, or body itself if there are no
+// limits.
+func limitBody(body io.ReadCloser, max int64, compressed *countingBody, ratio float64) io.ReadCloser {
+	if compressed == nil {
+		ratio = 0
+	}
+	if max <= 0 && ratio <= 0 {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, max: max, ratio: ratio, compressed: compressed}
+}
+
+func (self *limitedBody) Read(p []byte) (int, error) {
+	if self.err != nil {
+		return 0, self.err
+	}
+
+	// Reading one byte past the limit tells bodies that are exactly max
+	// bytes long apart from longer ones.
+	if self.max > 0 && int64(len(p)) > self.max-self.n+1 {
+		p = p[:self.max-self.n+1]
+	}
+
+	n, err := self.ReadCloser.Read(p)
+	self.n += int64(n)
+
+	if self.max > 0 && self.n > self.max {
+		self.err = ErrResponseTooLarge
+		return n - int(self.n-self.max), self.err
+	}
+
+	if self.ratio > 0 && self.n > ratioGuardMinBytes && float64(self.n) > self.ratio*float64(self.compressed.n) {
+		self.err = ErrResponseTooLarge
+		return n, self.err
+	}
+
+	return n, err
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	var err error
+
+	// 8MB of zeros compress into a few KB.
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	gz.Write(make([]byte, 8<<20))
+	gz.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(bomb.Bytes())
+		case "/chunked":
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`"`))
+			w.(http.Flusher).Flush()
+			w.Write(bytes.Repeat([]byte("a"), n-2))
+			w.Write([]byte(`"`))
+		default:
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			w.Write(bytes.Repeat([]byte("a"), n))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.MaxResponseBytes = 100
+
+	get := func(dst interface{}, path string, n int) error {
+		return client.R().Query("n", strconv.Itoa(n)).Into(dst).Get(context.Background(), path)
+	}
+
+	var buf []byte
+	var s string
+
+	if err = get(&buf, "/", 100); err != nil || len(buf) != 100 {
+		t.Fatalf("Expecting 100 bytes, got %d (%v).", len(buf), err)
+	}
+
+	if err = get(&buf, "/", 101); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	if err = get(&s, "/chunked", 100); err != nil || len(s) != 100 {
+		t.Fatalf("Expecting 100 bytes, got %d (%v).", len(s), err)
+	}
+
+	if err = get(&s, "/chunked", 101); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	// Streamed decoding stops at the limit too.
+	var v interface{}
+	if err = get(&v, "/chunked", 1000); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	// HEAD responses announce the size of the resource, without a body that
+	// could exceed the limit.
+	var res Response
+	if err = client.R().Query("n", "1000").Into(&res).Head(context.Background(), "/"); err != nil || res.ContentLength != 1000 {
+		t.Fatalf("Expecting a Content-Length of 1000, got %d (%v).", res.ContentLength, err)
+	}
+
+	// Per request limits.
+	if err = client.R().Query("n", "1000").MaxResponseBytes(-1).Into(&buf).Get(context.Background(), "/"); err != nil || len(buf) != 1000 {
+		t.Fatalf("Expecting 1000 bytes, got %d (%v).", len(buf), err)
+	}
+
+	if err = client.R().Query("n", "50").MaxResponseBytes(10).Into(&buf).Get(context.Background(), "/"); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	// The limit applies to decompressed data.
+	if err = get(&buf, "/bomb", 0); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	var body io.ReadCloser
+	if err = get(&body, "/chunked", 1000); err != nil {
+		t.Fatal(err)
+	}
+	buf, err = io.ReadAll(body)
+	body.Close()
+	if err != ErrResponseTooLarge || len(buf) != 100 {
+		t.Fatalf("Expecting ErrResponseTooLarge after 100 bytes, got %v after %d.", err, len(buf))
+	}
+
+	client.MaxResponseBytes = 0
+
+	if err = get(&buf, "/bomb", 0); err != nil || len(buf) != 8<<20 {
+		t.Fatalf("Expecting %d bytes, got %d (%v).", 8<<20, len(buf), err)
+	}
+
+	client.MaxCompressionRatio = 100
+
+	if err = get(&buf, "/bomb", 0); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	// Uncompressed responses have no ratio.
+	if err = get(&buf, "/", 2<<20); err != nil {
+		t.Fatal(err)
+	}
+}
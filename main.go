@@ -25,7 +25,7 @@ package rest
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
@@ -33,15 +33,16 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
-	"mime/multipart"
+	"mime"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
-	"path"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const debugEnv = `REST_DEBUG`
@@ -57,6 +58,7 @@ var (
 	ioReadCloserType = reflect.TypeOf((*io.ReadCloser)(nil)).Elem()
 	bytesBufferType  = reflect.TypeOf((**bytes.Buffer)(nil)).Elem()
 	restResponseType = reflect.TypeOf((*Response)(nil)).Elem()
+	multipartType    = reflect.TypeOf((*MultipartResponse)(nil)).Elem()
 )
 
 // Response can be used as a response value, useful when you need to work with
@@ -72,22 +74,6 @@ type Response struct {
 	Body []byte
 }
 
-// File can be used to represent a file that you'll later upload within a
-// multipart request.
-type File struct {
-	Name string
-	io.Reader
-}
-
-type FileMap map[string][]File
-
-// MultipartMessage struct for multipart requests, you can't generate a
-// MultipartMessage directly, use rest.NewMultipartMessage() instead.
-type MultipartMessage struct {
-	contentType string
-	buf         io.Reader
-}
-
 // Client is useful in case you need to communicate with an API and you'd like
 // to use the same prefix for all of your requests or in scenarios where it
 // would be handy to keep a session cookie.
@@ -99,10 +85,84 @@ type Client struct {
 	Prefix string
 	// Jar to store cookies.
 	CookieJar *cookiejar.Jar
-	// Optional tls transport
+	// Client used to send every request. When nil, requests are sent through
+	// Transport and stored into CookieJar.
+	HTTPClient *http.Client
+	// Round tripper used when HTTPClient is nil. Defaults to a transport with a
+	// connection pool shared by all clients that don't set one.
+	Transport http.RoundTripper
+	// TLS configuration applied on top of Transport, which must be nil or a
+	// *http.Transport. Requests fail with ErrTLSConfigNotApplicable when the
+	// configuration can't be applied, because Transport is some other round
+	// tripper or HTTPClient is set. The resulting transport is created once
+	// and reused.
+	TLSConfig *tls.Config
+	// Optional tls transport, takes precedence over Transport and TLSConfig.
+	//
+	// Deprecated: Use Transport or TLSConfig instead.
 	TlsTransport *http.Transport
+	// Makes JSON request bodies be encoded while they are sent, see
+	// Request.StreamJSON.
+	StreamJSON bool
+	// Limits the size of response bodies once decompressed, larger responses
+	// fail with ErrResponseTooLarge. io.ReadCloser destinations get a body
+	// that fails the same way. See WithMaxResponseBytes to change it for a
+	// single call. Zero means there's no limit.
+	MaxResponseBytes int64
+	// Limits how many times larger than the data received a decompressed
+	// response body can be, bodies that inflate more fail with
+	// ErrResponseTooLarge. It's checked once more than 1MB was decompressed.
+	// Zero means there's no limit.
+	MaxCompressionRatio float64
+	// Compresses request bodies, see WithCompression to change it for a
+	// single call.
+	Compression Compression
+	// Decides which status codes are successful, responses with any other
+	// status are returned as a *StatusError instead of being converted into
+	// the destination. A nil policy accepts any status. *Response destinations
+	// always receive the response, whatever its status. Problem documents
+	// override the policy, see ProblemDetails.
+	StatusPolicy StatusPolicy
+	// Limits for the duration of requests, see WithTimeouts to change them for
+	// a single call.
+	Timeouts Timeouts
+	// Decides whether failed attempts are sent again, no retries are made if
+	// nil.
+	RetryPolicy RetryPolicy
+	// Creates values to decode rejected responses into, see ErrorFactory.
+	// Setting a factory makes a nil StatusPolicy behave as RequireSuccess.
+	ErrorFactory ErrorFactory
+
+	mu           sync.Mutex
+	middleware   []Middleware
+	decoders     map[string]Decoder
+	tlsTransport *http.Transport
+	tlsBase      http.RoundTripper
+	tlsConfig    *tls.Config
+}
+
+// StatusPolicy reports whether a response with the given status code should be
+// treated as successful.
+type StatusPolicy func(statusCode int) bool
+
+// AcceptAll is a StatusPolicy that treats every status code as successful.
+func AcceptAll(statusCode int) bool {
+	return true
+}
+
+// RequireSuccess is a StatusPolicy that only accepts 2xx status codes.
+func RequireSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
 }
 
+// ErrorFactory returns a pointer to a new value that a rejected response with
+// the given status code should be decoded into, or nil if the body should not
+// be decoded. The value is exposed as the Value of the returned *StatusError,
+// if it implements the error interface it can also be retrieved with
+// errors.As. Problem documents are decoded into the value too, and into a
+// *ProblemDetails exposed as the Problem of the *StatusError.
+type ErrorFactory func(statusCode int) interface{}
+
 // DefaulClient is the default client used on top level functions like
 // rest.Get(), rest.Post(), rest.Delete() and rest.Put().
 var DefaultClient = new(Client)
@@ -141,14 +201,14 @@ func New(prefix string) (*Client, error) {
 	return self, nil
 }
 
+// NewTLS creates a new client, just like New, that uses the given TLS
+// configuration.
 func NewTLS(prefix string, tlsClient *tls.Config) (*Client, error) {
 	client, err := New(prefix)
 	if err != nil {
 		return client, err
 	}
-	client.TlsTransport = &http.Transport{
-		TLSClientConfig: tlsClient,
-	}
+	client.TLSConfig = tlsClient
 
 	return client, err
 }
@@ -164,61 +224,126 @@ func (self *Client) SetBasicAuth(username string, password string) {
 	self.Header.Set("Authorization", "Basic "+basicAuth(username, password))
 }
 
-func (self *Client) newMultipartRequest(dst interface{}, method string, addr *url.URL, body *MultipartMessage) error {
-	var res *http.Response
-	var req *http.Request
-
-	var err error
-
-	if body == nil {
-		return ErrCouldNotCreateMultipart
-	}
-
-	if req, err = http.NewRequest(method, addr.String(), body.buf); err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-Type", body.contentType)
+// requestBody describes a request body that can be produced as many times as
+// needed, so requests can be sent again.
+type requestBody struct {
+	contentType string
+	// Content coding applied to the body, if any.
+	contentEncoding string
+	// Length of the body, -1 if unknown.
+	size int64
+	open func() (io.ReadCloser, error)
+	// Bodies that can only be opened once can't be sent again.
+	oneShot bool
+	// Releases whatever holds the body until it's opened, if anything.
+	close func() error
+}
 
-	if res, err = self.do(req); err != nil {
-		return err
+// release closes a body that won't be opened because the request failed
+// early, once opened closing it is up to the transport.
+func (self *requestBody) release() {
+	if self != nil && self.close != nil {
+		self.close()
 	}
+}
 
-	if err = self.handleResponse(dst, res); err != nil {
-		return err
+func newBytesBody(contentType string, buf []byte) *requestBody {
+	return &requestBody{
+		contentType: contentType,
+		size:        int64(len(buf)),
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(buf)), nil
+		},
 	}
-
-	return nil
 }
 
-func (self *Client) newRequest(dst interface{}, method string, addr *url.URL, body *strings.Reader) error {
+func (self *Client) newRequest(ctx context.Context, dst interface{}, method string, addr *url.URL, header http.Header, body *requestBody) error {
 	var res *http.Response
 	var req *http.Request
 
 	var err error
 
+	// The options are handed down rather than read back from the response,
+	// whose request may have been replaced or dropped by middleware.
+	opts := optionsFrom(ctx)
+
+	if body != nil {
+		original := body
+		if body, err = self.compress(ctx, header, body); err != nil {
+			original.release()
+			return err
+		}
+		if fn := opts.uploadProgress; fn != nil {
+			body = withUploadProgress(body, fn)
+		}
+	}
+
 	if body == nil {
-		if req, err = http.NewRequest(method, addr.String(), nil); err != nil {
+		if req, err = http.NewRequestWithContext(ctx, method, addr.String(), nil); err != nil {
 			return err
 		}
 	} else {
-		if req, err = http.NewRequest(method, addr.String(), body); err != nil {
+		var r io.ReadCloser
+
+		if r, err = body.open(); err != nil {
 			return err
 		}
+
+		if req, err = http.NewRequestWithContext(ctx, method, addr.String(), r); err != nil {
+			r.Close()
+			return err
+		}
+
+		if !body.oneShot {
+			req.GetBody = body.open
+		}
+		req.ContentLength = body.size
+	}
+
+	// Copying headers, the ones of the request take precedence over the
+	// content type of the body, which takes precedence over the client's.
+	for k := range self.Header {
+		req.Header.Set(k, self.Header.Get(k))
+	}
+
+	if body != nil && body.contentType != "" {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+
+	if body != nil && body.contentEncoding != "" {
+		req.Header.Set("Content-Encoding", body.contentEncoding)
+	}
+
+	for k := range header {
+		req.Header[k] = header[k]
 	}
 
-	switch method {
-	case "POST", "PUT":
-		if req.Header.Get("Content-Type") == "" {
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+	// Like net/http, compression is not asked for on range requests.
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+
+	if req.Body == nil {
+		req.Header.Del("Content-Type")
+		req.Header.Del("Content-Length")
+	} else {
+		switch method {
+		case "POST", "PUT", "PATCH":
+			if req.Header.Get("Content-Type") == "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+			}
 		}
 	}
 
-	if res, err = self.do(req); err != nil {
+	if res, err = self.do(req, opts); err != nil {
+		// Middleware may return a response along with an error.
+		if res != nil && res.Body != nil {
+			res.Body.Close()
+		}
 		return err
 	}
 
-	if err = self.handleResponse(dst, res); err != nil {
+	if err = self.handleResponse(dst, res, opts); err != nil {
 		return err
 	}
 
@@ -229,200 +354,255 @@ func (self *Client) newRequest(dst interface{}, method string, addr *url.URL, bo
 // response body into the datatype given by dst (a pointer to a struct, map or
 // []byte array).
 func (self *Client) Put(dst interface{}, path string, data url.Values) error {
-	var addr *url.URL
-	var err error
-	var body *strings.Reader
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(path, "/")); err != nil {
-		return err
-	}
-
-	if data != nil {
-		body = strings.NewReader(data.Encode())
-	}
+	return self.PutContext(context.Background(), dst, path, data)
+}
 
-	return self.newRequest(dst, "PUT", addr, body)
+// PutContext is like Put but the request is bound to the given context, if
+// the context is cancelled before the response is read the request is
+// aborted.
+func (self *Client) PutContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().Form(data).Into(dst).Put(ctx, path)
 }
 
 // Delete performs a HTTP DELETE request and, when complete, attempts to
 // convert the response body into the datatype given by dst (a pointer to a
 // struct, map or []byte array).
 func (self *Client) Delete(dst interface{}, path string, data url.Values) error {
-	var addr *url.URL
-	var err error
-	var body *strings.Reader
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(path, "/")); err != nil {
-		return err
-	}
-
-	if data != nil {
-		body = strings.NewReader(data.Encode())
-	}
+	return self.DeleteContext(context.Background(), dst, path, data)
+}
 
-	return self.newRequest(dst, "DELETE", addr, body)
+// DeleteContext is like Delete but the request is bound to the given context.
+func (self *Client) DeleteContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().Form(data).Into(dst).Delete(ctx, path)
 }
 
 // PutMultipart performs a HTTP PUT multipart request and, when complete,
 // attempts to convert the response body into the datatype given by dst (a
 // pointer to a struct, map or []byte array).
 func (self *Client) PutMultipart(dst interface{}, uri string, data *MultipartMessage) error {
-	var addr *url.URL
-	var err error
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(uri, "/")); err != nil {
-		return err
-	}
+	return self.PutMultipartContext(context.Background(), dst, uri, data)
+}
 
-	return self.newMultipartRequest(dst, "PUT", addr, data)
+// PutMultipartContext is like PutMultipart but the request is bound to the
+// given context.
+func (self *Client) PutMultipartContext(ctx context.Context, dst interface{}, uri string, data *MultipartMessage) error {
+	return self.R().Multipart(data).Into(dst).Put(ctx, uri)
 }
 
 // PostMultipart performs a HTTP POST multipart request and, when complete,
 // attempts to convert the response body into the datatype given by dst (a
 // pointer to a struct, map or []byte array).
 func (self *Client) PostMultipart(dst interface{}, uri string, data *MultipartMessage) error {
-	var addr *url.URL
-	var err error
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(uri, "/")); err != nil {
-		return err
-	}
+	return self.PostMultipartContext(context.Background(), dst, uri, data)
+}
 
-	return self.newMultipartRequest(dst, "POST", addr, data)
+// PostMultipartContext is like PostMultipart but the request is bound to the
+// given context.
+func (self *Client) PostMultipartContext(ctx context.Context, dst interface{}, uri string, data *MultipartMessage) error {
+	return self.R().Multipart(data).Into(dst).Post(ctx, uri)
 }
 
 // PutRaw performs a HTTP PUT request with a custom body and, when complete,
 // attempts to convert the response body into the datatype given by dst (a
 // pointer to a struct, map or []byte array).
 func (self *Client) PutRaw(dst interface{}, path string, body []byte) error {
-	var addr *url.URL
-	var err error
-	var bodyReader *strings.Reader
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(path, "/")); err != nil {
-		return err
-	}
-
-	if body != nil {
-		bodyReader = strings.NewReader(string(body))
-	}
+	return self.PutRawContext(context.Background(), dst, path, body)
+}
 
-	return self.newRequest(dst, "PUT", addr, bodyReader)
+// PutRawContext is like PutRaw but the request is bound to the given context.
+func (self *Client) PutRawContext(ctx context.Context, dst interface{}, path string, body []byte) error {
+	return self.R().Body("", body).Into(dst).Put(ctx, path)
 }
 
 // PostRaw performs a HTTP POST request with a custom body and, when complete,
 // attempts to convert the response body into the datatype given by dst (a
 // pointer to a struct, map or []byte array).
 func (self *Client) PostRaw(dst interface{}, path string, body []byte) error {
-	var addr *url.URL
-	var err error
-	var bodyReader *strings.Reader
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(path, "/")); err != nil {
-		return err
-	}
-
-	if body != nil {
-		bodyReader = strings.NewReader(string(body))
-	}
+	return self.PostRawContext(context.Background(), dst, path, body)
+}
 
-	return self.newRequest(dst, "POST", addr, bodyReader)
+// PostRawContext is like PostRaw but the request is bound to the given
+// context.
+func (self *Client) PostRawContext(ctx context.Context, dst interface{}, path string, body []byte) error {
+	return self.R().Body("", body).Into(dst).Post(ctx, path)
 }
 
 // Post performs a HTTP POST request and, when complete, attempts to convert
 // the response body into the datatype given by dst (a pointer to a struct, map
 // or []byte array).
 func (self *Client) Post(dst interface{}, path string, data url.Values) error {
-	var addr *url.URL
-	var err error
-	var body *strings.Reader
-
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(path, "/")); err != nil {
-		return err
-	}
-
-	if data != nil {
-		body = strings.NewReader(data.Encode())
-	}
+	return self.PostContext(context.Background(), dst, path, data)
+}
 
-	return self.newRequest(dst, "POST", addr, body)
+// PostContext is like Post but the request is bound to the given context.
+func (self *Client) PostContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().Form(data).Into(dst).Post(ctx, path)
 }
 
-// Get performs a HTTP GET request and, when complete, attempts to convert the
-// response body into the datatype given by dst (a pointer to a struct, map or
-// []byte array).
-func (self *Client) Get(dst interface{}, path string, data url.Values) error {
-	var addr *url.URL
-	var err error
+// Patch performs a HTTP PATCH request and, when complete, attempts to convert
+// the response body into the datatype given by dst (a pointer to a struct, map
+// or []byte array).
+func (self *Client) Patch(dst interface{}, path string, data url.Values) error {
+	return self.PatchContext(context.Background(), dst, path, data)
+}
 
-	if addr, err = url.Parse(self.Prefix + strings.TrimLeft(path, "/")); err != nil {
-		return err
-	}
+// PatchContext is like Patch but the request is bound to the given context.
+func (self *Client) PatchContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().Form(data).Into(dst).Patch(ctx, path)
+}
 
-	if data != nil {
-		if addr.RawQuery == "" {
-			addr.RawQuery = data.Encode()
-		} else {
-			addr.RawQuery = addr.RawQuery + "&" + data.Encode()
-		}
-	}
+// PatchRaw performs a HTTP PATCH request with a custom body and, when
+// complete, attempts to convert the response body into the datatype given by
+// dst (a pointer to a struct, map or []byte array).
+func (self *Client) PatchRaw(dst interface{}, path string, body []byte) error {
+	return self.PatchRawContext(context.Background(), dst, path, body)
+}
 
-	return self.newRequest(dst, "GET", addr, nil)
+// PatchRawContext is like PatchRaw but the request is bound to the given
+// context.
+func (self *Client) PatchRawContext(ctx context.Context, dst interface{}, path string, body []byte) error {
+	return self.R().Body("", body).Into(dst).Patch(ctx, path)
 }
 
-// NewMultipartMessage creates a *MultipartMessage based on the given parameters.
-// This is useful for PostMultipart() and PutMultipart().
-func NewMultipartMessage(params url.Values, filemap FileMap) (*MultipartMessage, error) {
+// PatchMultipart performs a HTTP PATCH multipart request and, when complete,
+// attempts to convert the response body into the datatype given by dst (a
+// pointer to a struct, map or []byte array).
+func (self *Client) PatchMultipart(dst interface{}, uri string, data *MultipartMessage) error {
+	return self.PatchMultipartContext(context.Background(), dst, uri, data)
+}
 
-	dst := bytes.NewBuffer(nil)
+// PatchMultipartContext is like PatchMultipart but the request is bound to the
+// given context.
+func (self *Client) PatchMultipartContext(ctx context.Context, dst interface{}, uri string, data *MultipartMessage) error {
+	return self.R().Multipart(data).Into(dst).Patch(ctx, uri)
+}
 
-	body := multipart.NewWriter(dst)
+// Head performs a HTTP HEAD request, dst is usually a pointer to a Response,
+// which gets the status and headers of the response and an empty body. Any
+// other destination is left untouched, as there's no body to decode.
+func (self *Client) Head(dst interface{}, path string, data url.Values) error {
+	return self.HeadContext(context.Background(), dst, path, data)
+}
 
-	if filemap != nil {
-		for key, files := range filemap {
+// HeadContext is like Head but the request is bound to the given context.
+func (self *Client) HeadContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().QueryValues(data).Into(dst).Head(ctx, path)
+}
 
-			for _, file := range files {
+// Options performs a HTTP OPTIONS request and, when complete, attempts to
+// convert the response into the datatype given by dst. Use a pointer to a
+// Response to inspect headers like Allow or Access-Control-Allow-Methods.
+func (self *Client) Options(dst interface{}, path string, data url.Values) error {
+	return self.OptionsContext(context.Background(), dst, path, data)
+}
 
-				writer, err := body.CreateFormFile(key, path.Base(file.Name))
+// OptionsContext is like Options but the request is bound to the given
+// context.
+func (self *Client) OptionsContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().QueryValues(data).Into(dst).Options(ctx, path)
+}
 
-				if err != nil {
-					return nil, err
-				}
+// Do performs a HTTP request with an arbitrary method and, when complete,
+// attempts to convert the response body into the datatype given by dst (a
+// pointer to a struct, map or []byte array).
+//
+// The body can be nil, url.Values (sent as query parameters for GET, HEAD and
+// OPTIONS requests and as a form otherwise, like Get, Head, Options and Delete
+// do), []byte, string, *MultipartMessage or
+// io.Reader. Any other value is sent as JSON. Bodies given as an io.Reader are
+// read while being sent, so these requests are never retried.
+func (self *Client) Do(method string, dst interface{}, path string, body interface{}) error {
+	return self.DoContext(context.Background(), method, dst, path, body)
+}
 
-				if _, err = io.Copy(writer, file.Reader); err != nil {
-					return nil, err
-				}
-			}
+// DoContext is like Do but the request is bound to the given context.
+func (self *Client) DoContext(ctx context.Context, method string, dst interface{}, path string, body interface{}) error {
+	req := self.R().Into(dst)
+
+	switch b := body.(type) {
+	case nil:
+	case url.Values:
+		switch method {
+		case "GET", "HEAD", "OPTIONS":
+			req.QueryValues(b)
+		default:
+			req.Form(b)
 		}
+	case []byte:
+		req.Body("", b)
+	case string:
+		req.Body("", []byte(b))
+	case *MultipartMessage:
+		req.Multipart(b)
+	case io.Reader:
+		req.BodyReader("", b)
+	default:
+		req.JSON(b)
 	}
 
-	if params != nil {
-		for key := range params {
-			for _, value := range params[key] {
-				body.WriteField(key, value)
-			}
-		}
-	}
+	return req.Send(ctx, method, path)
+}
 
-	body.Close()
+// Get performs a HTTP GET request and, when complete, attempts to convert the
+// response body into the datatype given by dst (a pointer to a struct, map or
+// []byte array).
+func (self *Client) Get(dst interface{}, path string, data url.Values) error {
+	return self.GetContext(context.Background(), dst, path, data)
+}
 
-	return &MultipartMessage{body.FormDataContentType(), dst}, nil
+// GetContext is like Get but the request is bound to the given context. When
+// dst is an io.ReadCloser the context keeps governing the body, cancelling it
+// aborts any read in progress.
+func (self *Client) GetContext(ctx context.Context, dst interface{}, path string, data url.Values) error {
+	return self.R().QueryValues(data).Into(dst).Get(ctx, path)
 }
 
-// Returns the body of the request as a io.ReadCloser
-func (self *Client) body(res *http.Response) (io.ReadCloser, error) {
-	var body io.ReadCloser
-	var err error
+// Returns the body of the request as a io.ReadCloser, with its content codings
+// undone and the size limits of the call applied.
+func (self *Client) body(res *http.Response, opts callOptions) (io.ReadCloser, error) {
+	max := self.maxResponseBytes(opts)
+	names := contentCodings(res.Header)
+
+	if len(names) == 0 {
+		// The Content-Length of responses without a body, like those to HEAD
+		// requests, is the one of the resource.
+		if max > 0 && res.ContentLength > max && hasBody(res) {
+			res.Body.Close()
+			return nil, ErrResponseTooLarge
+		}
+		return limitBody(res.Body, max, nil, 0), nil
+	}
+
+	codings := make([]Encoding, 0, len(names))
 
-	if res.Header.Get("Content-Encoding") == "gzip" {
-		if body, err = gzip.NewReader(res.Body); err != nil {
+	for _, name := range names {
+		coding, err := lookupEncoding(name)
+		if err != nil {
+			discard(res.Body, res.Body)
 			return nil, err
 		}
-	} else {
-		body = res.Body
+		codings = append(codings, coding)
 	}
 
-	return body, nil
+	// Like net/http does with the bodies it decompresses, the headers describe
+	// the body we hand out.
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	compressed := &countingBody{ReadCloser: res.Body}
+
+	return limitBody(&decodedBody{body: compressed, codings: codings}, max, compressed, self.MaxCompressionRatio), nil
+}
+
+// hasBody reports whether res may come with a body, responses to HEAD requests
+// never do.
+func hasBody(res *http.Response) bool {
+	if res.Body == http.NoBody {
+		return false
+	}
+	return res.Request == nil || res.Request.Method != http.MethodHead
 }
 
 func fromBytes(dst reflect.Value, buf []byte) error {
@@ -471,14 +651,184 @@ func fromBytes(dst reflect.Value, buf []byte) error {
 	return fmt.Errorf(ErrCouldNotConvert.Error(), reflect.TypeOf(buf), dst.Type())
 }
 
-func (self *Client) handleResponse(dst interface{}, res *http.Response) error {
+// statusPolicy returns the policy that applies to a call with the given
+// options.
+func (self *Client) statusPolicy(opts callOptions) StatusPolicy {
+	if self.StatusPolicy != nil {
+		return self.StatusPolicy
+	}
+	if opts.errorDst != nil || self.ErrorFactory != nil {
+		return RequireSuccess
+	}
+	return AcceptAll
+}
+
+// errorDestination returns the value a rejected response should be decoded
+// into, if any.
+func (self *Client) errorDestination(opts callOptions, statusCode int) interface{} {
+	if opts.errorDst != nil {
+		return opts.errorDst
+	}
+	if self.ErrorFactory != nil {
+		return self.ErrorFactory(statusCode)
+	}
+	return nil
+}
+
+// newStatusError reads and closes the response body and returns a *StatusError
+// describing the response. If errDst is not nil the body is also decoded into
+// it.
+func (self *Client) newStatusError(res *http.Response, body io.ReadCloser, errDst interface{}) error {
+	defer body.Close()
+
+	buf, err := ioutil.ReadAll(body)
+
+	if debugLevelEnabled(debugLevelVerbose) {
+		log.Printf("Body:\n%s\n", string(buf))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	statusErr := &StatusError{
+		Status:     res.Status,
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       buf,
+	}
+
+	if res.Request != nil {
+		statusErr.Method = res.Request.Method
+		statusErr.URL = res.Request.URL.String()
+	}
+
+	contentType := res.Header.Get("Content-Type")
+
+	if errDst != nil {
+		// A body that can't be converted still leaves us with a meaningful
+		// status error.
+		if self.convert(errDst, contentType, buf) == nil {
+			statusErr.Value = errDst
+		}
+	}
+
+	// Problem documents are always available as such, even when the caller
+	// decodes them into a type of their own.
+	if mediaType(contentType) == problemMediaType {
+		if problem, ok := statusErr.Value.(*ProblemDetails); ok {
+			statusErr.Problem = problem
+		} else if problem := (&ProblemDetails{}); self.convert(problem, contentType, buf) == nil {
+			statusErr.Problem = problem
+		}
+	}
+
+	return statusErr
+}
+
+// newProblem decodes a problem document sent with a successful status, there's
+// no status to complain about so it's returned on its own.
+func (self *Client) newProblem(res *http.Response, body io.ReadCloser) error {
+	defer body.Close()
 
-	body, err := self.body(res)
+	buf, err := ioutil.ReadAll(body)
+
+	if debugLevelEnabled(debugLevelVerbose) {
+		log.Printf("Body:\n%s\n", string(buf))
+	}
 
 	if err != nil {
 		return err
 	}
 
+	problem := &ProblemDetails{}
+
+	if err = self.convert(problem, res.Header.Get("Content-Type"), buf); err != nil {
+		return err
+	}
+
+	return problem
+}
+
+// mediaType returns the lowercased media type of a Content-Type header value,
+// without parameters.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt := strings.SplitN(contentType, ";", 2)[0]
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// convert attempts to convert buf into dst, using the decoder registered for
+// the given content type. Strings and []byte arrays get the raw body.
+func (self *Client) convert(dst interface{}, contentType string, buf []byte) error {
+	rv := reflect.ValueOf(dst)
+
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrDestinationNotAPointer
+	}
+
+	if !isRaw(rv.Elem().Type()) {
+		if decoder := self.decoder(mediaType(contentType)); decoder != nil {
+			return decode(decoder, bytes.NewReader(buf), contentType, dst)
+		}
+	}
+
+	return fromBytes(rv.Elem(), buf)
+}
+
+// isRaw reports whether values of type t get the raw response body.
+func isRaw(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// handleResponse converts the response into dst. The response body is always
+// closed before returning, unless dst is a pointer to an io.ReadCloser or a
+// MultipartResponse, which becomes the owner of the body.
+func (self *Client) handleResponse(dst interface{}, res *http.Response, opts callOptions) error {
+
+	body, err := self.body(res, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if opts.downloadProgress != nil {
+		body = newProgressReader(body, res.ContentLength, opts.downloadProgress)
+	}
+
+	owned := true
+	defer func() {
+		if owned {
+			discard(res.Body, body)
+		}
+	}()
+
+	if _, ok := dst.(*Response); !ok {
+		accept := self.statusPolicy(opts)
+		// Problem documents describe errors whatever the status policy says.
+		problem := mediaType(res.Header.Get("Content-Type")) == problemMediaType
+
+		if problem && RequireSuccess(res.StatusCode) {
+			return self.newProblem(res, body)
+		}
+
+		if problem || !accept(res.StatusCode) {
+			errDst := self.errorDestination(opts, res.StatusCode)
+			if errDst == nil && problem {
+				errDst = &ProblemDetails{}
+			}
+			return self.newStatusError(res, body, errDst)
+		}
+	}
+
 	if dst == nil {
 		return nil
 	}
@@ -488,6 +838,12 @@ func (self *Client) handleResponse(dst interface{}, res *http.Response) error {
 		return ErrDestinationNotAPointer
 	}
 
+	// Responses to HEAD requests have no body, whatever their Content-Type
+	// says, so there's nothing to decode into dst.
+	if res.Request != nil && res.Request.Method == http.MethodHead && rv.Elem().Type() != restResponseType {
+		return nil
+	}
+
 	t := res.Header.Get("Content-Type")
 
 	switch rv.Elem().Type() {
@@ -516,7 +872,15 @@ func (self *Client) handleResponse(dst interface{}, res *http.Response) error {
 
 		rv.Elem().Set(reflect.ValueOf(r))
 	case ioReadCloserType:
+		owned = false
 		rv.Elem().Set(reflect.ValueOf(body))
+	case multipartType:
+		mr, err := newMultipartResponse(self, res, body)
+		if err != nil {
+			return err
+		}
+		owned = false
+		*dst.(*MultipartResponse) = *mr
 	case bytesBufferType:
 		buf, err := ioutil.ReadAll(body)
 
@@ -532,6 +896,14 @@ func (self *Client) handleResponse(dst interface{}, res *http.Response) error {
 
 		rv.Elem().Set(reflect.ValueOf(dst))
 	default:
+		// Decoding straight from the body avoids holding it in memory, unless
+		// we need it for debugging.
+		if !isRaw(rv.Elem().Type()) && !debugLevelEnabled(debugLevelVerbose) {
+			if decoder := self.decoder(mediaType(t)); decoder != nil {
+				return decode(decoder, body, t, dst)
+			}
+		}
+
 		buf, err := ioutil.ReadAll(body)
 
 		if debugLevelEnabled(debugLevelVerbose) {
@@ -542,16 +914,7 @@ func (self *Client) handleResponse(dst interface{}, res *http.Response) error {
 			return err
 		}
 
-		if strings.HasPrefix(t, "application/json") == true {
-			if rv.Elem().Kind() == reflect.Struct || rv.Elem().Kind() == reflect.Map {
-				err = json.Unmarshal(buf, dst)
-				return err
-			}
-		}
-
-		err = fromBytes(rv.Elem(), buf)
-
-		if err != nil {
+		if err = self.convert(dst, t, buf); err != nil {
 			return err
 		}
 	}
@@ -559,55 +922,129 @@ func (self *Client) handleResponse(dst interface{}, res *http.Response) error {
 	return nil
 }
 
-func (self *Client) do(req *http.Request) (*http.Response, error) {
-	var client http.Client
-	if self.TlsTransport != nil {
-		client = http.Client{
-			Transport: self.TlsTransport,
+func (self *Client) do(req *http.Request, opts callOptions) (*http.Response, error) {
+	var sent atomic.Bool
+
+	// Like http.Client.Do, the body is closed even when nothing is sent, as
+	// when middleware answer on their own.
+	defer func() {
+		if !sent.Load() && req.Body != nil {
+			req.Body.Close()
 		}
-	} else {
-		client = http.Client{}
+	}()
+
+	client, err := self.httpClient()
+	if err != nil {
+		return nil, err
 	}
 
-	// Adding cookie jar
-	if self.CookieJar != nil {
-		client.Jar = self.CookieJar
+	timeouts := self.Timeouts.override(opts.timeouts)
+
+	doer := self.chain(DoerFunc(func(req *http.Request) (*http.Response, error) {
+		sent.Store(true)
+		return self.send(client, req, timeouts)
+	}), opts)
+
+	if timeouts.Total <= 0 {
+		res, err := doer.Do(req)
+		if err != nil {
+			return res, err
+		}
+		return complete(req, res), nil
 	}
 
-	// Copying headers
-	for k := range self.Header {
-		req.Header.Set(k, self.Header.Get(k))
+	ctx, cancel := context.WithTimeoutCause(req.Context(), timeouts.Total, &TimeoutError{
+		Phase: TimeoutTotal,
+		Limit: timeouts.Total,
+	})
+
+	res, err := doer.Do(req.WithContext(ctx))
+
+	if err != nil {
+		cancel()
+		return res, err
 	}
 
-	if req.Body == nil {
-		req.Header.Del("Content-Type")
-		req.Header.Del("Content-Length")
+	// The limit also applies to reading the body.
+	res = complete(req, res)
+	res.Body = &cancelBody{ReadCloser: res.Body, ctx: ctx, cancel: cancel}
+
+	return res, nil
+}
+
+// complete fills in what middleware answering on their own may leave out of
+// res: the request it answers and, when there's none, an empty body.
+func complete(req *http.Request, res *http.Response) *http.Response {
+	if res.Request == nil {
+		res.Request = req
+	}
+	if res.Body == nil {
+		res.Body = http.NoBody
 	}
+	return res
+}
 
-	res, err := client.Do(req)
+// send sends req as many times as the retry policy allows.
+func (self *Client) send(client *http.Client, req *http.Request, timeouts Timeouts) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := self.attempt(client, req, timeouts)
 
-	if debugLevelEnabled(debugLevelVerbose) {
+		if debugLevelEnabled(debugLevelVerbose) {
+			debugExchange(req, res)
+		}
 
-		log.Printf("Fetching %v\n", req.URL.String())
+		if self.RetryPolicy == nil {
+			return res, err
+		}
 
-		log.Printf("> %s %s", req.Method, req.Proto)
-		for k := range req.Header {
-			for kk := range req.Header[k] {
-				log.Printf("> %s: %s", k, req.Header[k][kk])
+		delay, retry := self.RetryPolicy.Retry(attempt, req, res, err)
+
+		if !retry || (req.Body != nil && req.GetBody == nil) {
+			return res, err
+		}
+
+		if res != nil {
+			discard(res.Body, res.Body)
+		}
+
+		if debugLevelEnabled(debugLevelVerbose) {
+			log.Printf("Retrying %s %s in %v (attempt %d)", req.Method, req.URL, delay, attempt+1)
+		}
+
+		if err = sleep(req.Context(), delay); err != nil {
+			return nil, timeoutCause(req.Context(), err)
+		}
+
+		next := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if next.Body, err = req.GetBody(); err != nil {
+				return nil, err
 			}
 		}
+		req = next
+	}
+}
+
+func debugExchange(req *http.Request, res *http.Response) {
+	log.Printf("Fetching %v\n", req.URL.String())
+
+	log.Printf("> %s %s", req.Method, req.Proto)
+	for k := range req.Header {
+		for kk := range req.Header[k] {
+			log.Printf("> %s: %s", k, req.Header[k][kk])
+		}
+	}
 
+	if res != nil {
 		log.Printf("< %s %s", res.Proto, res.Status)
 		for k := range res.Header {
 			for kk := range res.Header[k] {
 				log.Printf("< %s: %s", k, res.Header[k][kk])
 			}
 		}
-
-		log.Printf("\n")
 	}
 
-	return res, err
+	log.Printf("\n")
 }
 
 // Get performs a HTTP GET request using the default client and, when complete,
@@ -617,6 +1054,11 @@ func Get(dest interface{}, uri string, data url.Values) error {
 	return DefaultClient.Get(dest, uri, data)
 }
 
+// GetContext is like Get but the request is bound to the given context.
+func GetContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.GetContext(ctx, dest, uri, data)
+}
+
 // Post performs a HTTP POST request using the default client and, when
 // complete, attempts to convert the response body into the datatype given by
 // dst (a pointer to a struct, map or []byte array).
@@ -624,6 +1066,11 @@ func Post(dest interface{}, uri string, data url.Values) error {
 	return DefaultClient.Post(dest, uri, data)
 }
 
+// PostContext is like Post but the request is bound to the given context.
+func PostContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.PostContext(ctx, dest, uri, data)
+}
+
 // Put performs a HTTP PUT request using the default client and, when complete,
 // attempts to convert the response body into the datatype given by dst (a
 // pointer to a struct, map or []byte array).
@@ -631,6 +1078,11 @@ func Put(dest interface{}, uri string, data url.Values) error {
 	return DefaultClient.Put(dest, uri, data)
 }
 
+// PutContext is like Put but the request is bound to the given context.
+func PutContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.PutContext(ctx, dest, uri, data)
+}
+
 // Delete performs a HTTP DELETE request using the default client and, when
 // complete, attempts to convert the response body into the datatype given by
 // dst (a pointer to a struct, map or []byte array).
@@ -638,6 +1090,11 @@ func Delete(dest interface{}, uri string, data url.Values) error {
 	return DefaultClient.Delete(dest, uri, data)
 }
 
+// DeleteContext is like Delete but the request is bound to the given context.
+func DeleteContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.DeleteContext(ctx, dest, uri, data)
+}
+
 // PostMultipart performs a HTTP POST multipart request using the default
 // client and, when complete, attempts to convert the response body into the
 // datatype given by dst (a pointer to a struct, map or []byte array).
@@ -645,9 +1102,103 @@ func PostMultipart(dest interface{}, uri string, data *MultipartMessage) error {
 	return DefaultClient.PostMultipart(dest, uri, data)
 }
 
+// PostMultipartContext is like PostMultipart but the request is bound to the
+// given context.
+func PostMultipartContext(ctx context.Context, dest interface{}, uri string, data *MultipartMessage) error {
+	return DefaultClient.PostMultipartContext(ctx, dest, uri, data)
+}
+
 // PutMultipart performs a HTTP PUT multipart request using the default client
 // and, when complete, attempts to convert the response body into the datatype
 // given by dst (a pointer to a struct, map or []byte array).
 func PutMultipart(dest interface{}, uri string, data *MultipartMessage) error {
 	return DefaultClient.PutMultipart(dest, uri, data)
 }
+
+// PutMultipartContext is like PutMultipart but the request is bound to the
+// given context.
+func PutMultipartContext(ctx context.Context, dest interface{}, uri string, data *MultipartMessage) error {
+	return DefaultClient.PutMultipartContext(ctx, dest, uri, data)
+}
+
+// Patch performs a HTTP PATCH request using the default client and, when
+// complete, attempts to convert the response body into the datatype given by
+// dst (a pointer to a struct, map or []byte array).
+func Patch(dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.Patch(dest, uri, data)
+}
+
+// PatchContext is like Patch but the request is bound to the given context.
+func PatchContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.PatchContext(ctx, dest, uri, data)
+}
+
+// PatchMultipart performs a HTTP PATCH multipart request using the default
+// client and, when complete, attempts to convert the response body into the
+// datatype given by dst (a pointer to a struct, map or []byte array).
+func PatchMultipart(dest interface{}, uri string, data *MultipartMessage) error {
+	return DefaultClient.PatchMultipart(dest, uri, data)
+}
+
+// PatchMultipartContext is like PatchMultipart but the request is bound to the
+// given context.
+func PatchMultipartContext(ctx context.Context, dest interface{}, uri string, data *MultipartMessage) error {
+	return DefaultClient.PatchMultipartContext(ctx, dest, uri, data)
+}
+
+// Head performs a HTTP HEAD request using the default client.
+func Head(dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.Head(dest, uri, data)
+}
+
+// HeadContext is like Head but the request is bound to the given context.
+func HeadContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.HeadContext(ctx, dest, uri, data)
+}
+
+// Options performs a HTTP OPTIONS request using the default client.
+func Options(dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.Options(dest, uri, data)
+}
+
+// OptionsContext is like Options but the request is bound to the given
+// context.
+func OptionsContext(ctx context.Context, dest interface{}, uri string, data url.Values) error {
+	return DefaultClient.OptionsContext(ctx, dest, uri, data)
+}
+
+// Do performs a HTTP request with an arbitrary method using the default
+// client, see Client.Do.
+func Do(method string, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.Do(method, dest, uri, body)
+}
+
+// DoContext is like Do but the request is bound to the given context.
+func DoContext(ctx context.Context, method string, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.DoContext(ctx, method, dest, uri, body)
+}
+
+// PostRawContext performs a HTTP POST request with a custom body using the
+// default client, the request is bound to the given context.
+func PostRawContext(ctx context.Context, dest interface{}, uri string, body []byte) error {
+	return DefaultClient.PostRawContext(ctx, dest, uri, body)
+}
+
+// PutRawContext performs a HTTP PUT request with a custom body using the
+// default client, the request is bound to the given context.
+func PutRawContext(ctx context.Context, dest interface{}, uri string, body []byte) error {
+	return DefaultClient.PutRawContext(ctx, dest, uri, body)
+}
+
+// PatchRaw performs a HTTP PATCH request with a custom body using the default
+// client and, when complete, attempts to convert the response body into the
+// datatype given by dst (a pointer to a struct, map or []byte array).
+func PatchRaw(dest interface{}, uri string, body []byte) error {
+	return DefaultClient.PatchRaw(dest, uri, body)
+}
+
+// PatchRawContext performs a HTTP PATCH request with a custom body using the
+// default client, the request is bound to the given context.
+func PatchRawContext(ctx context.Context, dest interface{}, uri string, body []byte) error {
+	return DefaultClient.PatchRawContext(ctx, dest, uri, body)
+}
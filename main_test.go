@@ -22,13 +22,18 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"menteslibres.net/gosexy/dig"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 )
@@ -485,3 +490,416 @@ func TestBasicAuth(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestGetContext(t *testing.T) {
+	var err error
+
+	block := make(chan struct{})
+	defer close(block)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	var buf []byte
+	if err = GetContext(ctx, &buf, srv.URL, nil); err == nil {
+		t.Fatalf("Expecting an error.")
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expecting context.DeadlineExceeded, got %v.", err)
+	}
+
+	// Streaming destinations keep being governed by the context.
+	ctx, cancel = context.WithCancel(context.Background())
+
+	var body io.ReadCloser
+	if err = GetContext(ctx, &body, srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	cancel()
+
+	if _, err = ioutil.ReadAll(body); err == nil {
+		t.Fatalf("Expecting read to be aborted.")
+	}
+}
+
+func TestStatusPolicy(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "not found"}`))
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a policy every status is decoded, as usual.
+	var buf map[string]interface{}
+	if err = client.Get(&buf, "/missing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["message"] != "not found" {
+		t.Fatalf("Expecting decoded body, got %v.", buf)
+	}
+
+	client.StatusPolicy = RequireSuccess
+
+	buf = nil
+	err = client.Get(&buf, "/missing", nil)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expecting *StatusError, got %v.", err)
+	}
+
+	if statusErr.StatusCode != http.StatusNotFound || statusErr.Method != "GET" || statusErr.URL != srv.URL+"/missing" {
+		t.Fatalf("Unexpected error value %#v.", statusErr)
+	}
+
+	if string(statusErr.Body) != `{"message": "not found"}` {
+		t.Fatalf("Unexpected body %q.", statusErr.Body)
+	}
+
+	if buf != nil {
+		t.Fatalf("Destination must not be touched on error.")
+	}
+
+	// *Response destinations receive every status.
+	var res Response
+	if err = client.Get(&res, "/missing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting 404, got %d.", res.StatusCode)
+	}
+}
+
+type apiError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+func (self *apiError) Error() string {
+	return self.Message
+}
+
+func TestErrorDestination(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message": "already exists", "code": 17}`))
+			return
+		}
+		w.Write([]byte(`{"message": "ok"}`))
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Per call destination.
+	var apiErr apiError
+	var buf map[string]interface{}
+
+	ctx := WithErrorDestination(context.Background(), &apiErr)
+
+	if err = client.GetContext(ctx, &buf, "/ok", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["message"] != "ok" || apiErr.Message != "" {
+		t.Fatalf("Expecting only the success destination to be decoded.")
+	}
+
+	buf = nil
+	err = client.GetContext(ctx, &buf, "/fail", nil)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusConflict {
+		t.Fatalf("Expecting *StatusError, got %v.", err)
+	}
+
+	if buf != nil {
+		t.Fatalf("Success destination must not be decoded on failure.")
+	}
+
+	if apiErr.Code != 17 || statusErr.Value != &apiErr {
+		t.Fatalf("Expecting decoded error value, got %#v.", apiErr)
+	}
+
+	// Client wide factory.
+	client.ErrorFactory = func(statusCode int) interface{} {
+		if statusCode >= 400 && statusCode < 500 {
+			return &apiError{}
+		}
+		return nil
+	}
+
+	err = client.Get(&buf, "/fail", nil)
+
+	var target *apiError
+	if !errors.As(err, &target) {
+		t.Fatalf("Expecting *apiError, got %v.", err)
+	}
+
+	if target.Message != "already exists" {
+		t.Fatalf("Unexpected message %q.", target.Message)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{
+			"type": "https://example.com/probs/out-of-credit",
+			"title": "You do not have enough credit.",
+			"status": 403,
+			"detail": "Your current balance is 30, but that costs 50.",
+			"instance": "/account/12345/msgs/abc",
+			"balance": 30
+		}`))
+	}))
+	defer srv.Close()
+
+	var buf map[string]interface{}
+
+	err = Get(&buf, srv.URL, nil)
+
+	var problem *ProblemDetails
+	if !errors.As(err, &problem) {
+		t.Fatalf("Expecting *ProblemDetails, got %v.", err)
+	}
+
+	if problem.Type != "https://example.com/probs/out-of-credit" || problem.Status != 403 || problem.Instance != "/account/12345/msgs/abc" {
+		t.Fatalf("Unexpected problem %#v.", problem)
+	}
+
+	if problem.Extensions["balance"] != float64(30) {
+		t.Fatalf("Expecting balance extension, got %v.", problem.Extensions)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expecting *StatusError, got %v.", err)
+	}
+
+	// Error destinations get the document too, without hiding the problem.
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.ErrorFactory = func(statusCode int) interface{} {
+		return &apiError{}
+	}
+
+	err = client.Get(&buf, "/", nil)
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || !errors.As(err, &statusErr) || statusErr.Value != apiErr {
+		t.Fatalf("Expecting *apiError, got %v.", err)
+	}
+
+	if !errors.As(err, &problem) || statusErr.Problem != problem || problem.Detail != "Your current balance is 30, but that costs 50." {
+		t.Fatalf("Expecting *ProblemDetails, got %v.", err)
+	}
+
+	// Type defaults to about:blank.
+	problem = &ProblemDetails{}
+	if err = json.Unmarshal([]byte(`{"title": "Oops", "type": 42}`), problem); err != nil {
+		t.Fatal(err)
+	}
+
+	if problem.Type != "about:blank" || problem.Title != "Oops" {
+		t.Fatalf("Unexpected problem %#v.", problem)
+	}
+
+	// Problem documents sent with a successful status are still errors, but
+	// there's no status to report.
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.Write([]byte(`{"title": "Partially applied", "detail": "Two of three items were saved"}`))
+	}))
+	defer srv.Close()
+
+	buf = nil
+	err = Get(&buf, srv.URL, nil)
+
+	if !errors.As(err, &problem) || problem.Title != "Partially applied" {
+		t.Fatalf("Expecting *ProblemDetails, got %v.", err)
+	}
+
+	if errors.As(err, &statusErr) {
+		t.Fatalf("Expecting no *StatusError for a successful status, got %v.", err)
+	}
+
+	if err.Error() != "Problem: Partially applied: Two of three items were saved." {
+		t.Fatalf("Unexpected message %q.", err.Error())
+	}
+
+	if buf != nil {
+		t.Fatalf("Expecting the destination to be left untouched, got %v.", buf)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	var buf map[string]interface{}
+	var err error
+
+	err = client.Patch(&buf, "/search?foo=the+quick", url.Values{"bar": {"brown fox"}})
+
+	if err != nil {
+		t.Fatalf("Failed test: %s\n", err.Error())
+	}
+
+	if buf["method"].(string) != "PATCH" {
+		t.Fatalf("Test failed.")
+	}
+
+	if buf["post"].(map[string]interface{})["bar"].([]interface{})[0].(string) != "brown fox" {
+		t.Fatalf("Test failed.")
+	}
+
+	body, err := NewMultipartMessage(url.Values{"foo": {"bar"}}, nil)
+
+	if err = client.PatchMultipart(&buf, "/patch", body); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["method"].(string) != "PATCH" {
+		t.Fatalf("Test failed.")
+	}
+
+	if buf["post"].(map[string]interface{})["foo"].([]interface{})[0].(string) != "bar" {
+		t.Fatalf("Test failed.")
+	}
+
+	if err = PatchRaw(&buf, "http://"+testServer+"/patch", []byte(`{"op": "add"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["method"].(string) != "PATCH" {
+		t.Fatalf("Test failed.")
+	}
+}
+
+func TestHeadOptions(t *testing.T) {
+	var res Response
+	var err error
+
+	if err = client.Head(&res, "/search", url.Values{"term": {"gopher"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/json" || len(res.Body) != 0 {
+		t.Fatalf("Unexpected HEAD response %#v.", res)
+	}
+
+	// The JSON Content-Type of a HEAD response is not followed by a body.
+	head := map[string]interface{}{"untouched": true}
+
+	if err = client.Head(&head, "/search", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = client.Do("HEAD", &head, "/search", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(head) != 1 || head["untouched"] != true {
+		t.Fatalf("Expecting the destination to be left untouched, got %v.", head)
+	}
+
+	var buf map[string]interface{}
+
+	if err = client.Options(&buf, "/search", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["method"].(string) != "OPTIONS" {
+		t.Fatalf("Test failed.")
+	}
+}
+
+func TestDo(t *testing.T) {
+	var buf map[string]interface{}
+	var err error
+
+	if err = client.Do("PROPFIND", &buf, "/dav", []byte(`<propfind/>`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["method"].(string) != "PROPFIND" {
+		t.Fatalf("Test failed.")
+	}
+
+	if err = client.Do("GET", &buf, "/search", url.Values{"term": {"gopher"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["url"].(string) != "/search?term=gopher" {
+		t.Fatalf("Test failed.")
+	}
+
+	// Same encoding as Options.
+	if err = client.Do("OPTIONS", &buf, "/search", url.Values{"term": {"gopher"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["method"].(string) != "OPTIONS" || buf["url"].(string) != "/search?term=gopher" {
+		t.Fatalf("Test failed.")
+	}
+
+	// Same encoding as Delete.
+	if err = client.Do("DELETE", &buf, "/search", url.Values{"term": {"gopher"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The body is echoed in base64, "dGVybT1nb3BoZXI=" is "term=gopher".
+	if buf["url"].(string) != "/search" || buf["body"].(string) != "dGVybT1nb3BoZXI=" {
+		t.Fatalf("Test failed.")
+	}
+
+	if err = client.Do("MKCOL", &buf, "/dav", strings.NewReader("streamed")); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["method"].(string) != "MKCOL" {
+		t.Fatalf("Test failed.")
+	}
+
+	if err = client.Do("REPORT", &buf, "/dav", map[string]string{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf["header"].(map[string]interface{})["Content-Type"].([]interface{})[0].(string) != jsonContentType {
+		t.Fatalf("Test failed.")
+	}
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+)
+
+// Doer sends a HTTP request and returns its response.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc allows ordinary functions to be used as Doers.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls fn(req).
+func (fn DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// Middleware wraps a Doer to add behaviour around requests, like logging,
+// authentication or metrics. A middleware sees each request once, whatever
+// the number of attempts made by the client's RetryPolicy, and receives the
+// response before it is converted into the destination. Middleware may answer
+// on their own, responses without a Request or a Body are taken as answering
+// the request given and having an empty body.
+type Middleware func(next Doer) Doer
+
+// Use adds middleware to the chain every request of the client goes through.
+// Middleware run in the order they were added, the first one being the
+// outermost.
+func (self *Client) Use(middleware ...Middleware) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.middleware = append(self.middleware, middleware...)
+}
+
+// WithMiddleware returns a copy of ctx that makes the request go through the
+// given middleware too, after the ones of the client.
+func WithMiddleware(ctx context.Context, middleware ...Middleware) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.middleware = append(opts.middleware[:len(opts.middleware):len(opts.middleware)], middleware...)
+	})
+}
+
+// chain wraps doer with the client's and the call's middleware.
+func (self *Client) chain(doer Doer, opts callOptions) Doer {
+	self.mu.Lock()
+	middleware := append(self.middleware[:len(self.middleware):len(self.middleware)], opts.middleware...)
+	self.mu.Unlock()
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		doer = middleware[i](doer)
+	}
+
+	return doer
+}
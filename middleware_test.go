@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+
+	trace := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				req.Header.Add("X-Trace", name)
+				return next.Do(req)
+			})
+		}
+	}
+
+	client.Use(trace("a"), trace("b"))
+
+	var buf string
+
+	ctx := WithMiddleware(context.Background(), trace("c"))
+
+	if err = client.GetContext(ctx, &buf, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(calls, ",") != "a,b,c" || buf != "a" {
+		t.Fatalf("Unexpected middleware order %v (%q).", calls, buf)
+	}
+
+	// Per call middleware don't stick.
+	calls = nil
+
+	if err = client.Get(&buf, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(calls, ",") != "a,b" {
+		t.Fatalf("Unexpected middleware calls %v.", calls)
+	}
+
+	// Middleware can answer on their own.
+	cached := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"cached": true}`)),
+				Request:    req,
+			}, nil
+		})
+	}
+
+	var res map[string]interface{}
+
+	if err = client.GetContext(WithMiddleware(context.Background(), cached), &res, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if res["cached"] != true {
+		t.Fatalf("Expecting cached response, got %v.", res)
+	}
+
+	// The options of the call still apply to responses that are not tied to
+	// the request they answer.
+	detached := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				Status:     "409 Conflict",
+				StatusCode: http.StatusConflict,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"reason": "detached"}`)),
+			}, nil
+		})
+	}
+
+	var reason struct {
+		Reason string `json:"reason"`
+	}
+
+	ctx = WithMiddleware(context.Background(), detached)
+
+	err = client.GetContext(WithErrorDestination(ctx, &reason), &res, "/", nil)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Value != &reason || reason.Reason != "detached" {
+		t.Fatalf("Expecting the error destination to be used, got %v.", err)
+	}
+
+	if statusErr.Method != "GET" {
+		t.Fatalf("Expecting the method of the request, got %q.", statusErr.Method)
+	}
+
+	if err = client.GetContext(WithMaxResponseBytes(ctx, 4), &res, "/", nil); err != ErrResponseTooLarge {
+		t.Fatalf("Expecting ErrResponseTooLarge, got %v.", err)
+	}
+
+	// Responses without a body are taken as empty.
+	noContent := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Header:     http.Header{},
+			}, nil
+		})
+	}
+
+	ctx = WithMiddleware(context.Background(), noContent)
+
+	var full Response
+	if err = client.GetContext(ctx, &full, "/", nil); err != nil || full.StatusCode != http.StatusNoContent || len(full.Body) != 0 {
+		t.Fatalf("Expecting an empty 204 response, got %v (%v).", full, err)
+	}
+
+	ctx = WithTimeouts(ctx, Timeouts{Total: time.Second})
+
+	if err = client.GetContext(ctx, &buf, "/", nil); err != nil || buf != "" {
+		t.Fatalf("Expecting an empty body, got %q (%v).", buf, err)
+	}
+}
@@ -0,0 +1,422 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File can be used to represent a file that you'll later upload within a
+// multipart request.
+type File struct {
+	Name string
+	io.Reader
+}
+
+type FileMap map[string][]File
+
+// Part is a part of a multipart message, see NewMultipartParts. Name and
+// FileName make up the Content-Disposition header of the part, they and
+// ContentType take precedence over the same headers in Header.
+type Part struct {
+	// Name of the form field, only used in multipart/form-data messages.
+	Name string
+	// Name of the file, if the part is a file. It's sent as it is, unlike the
+	// Name of a File.
+	FileName string
+	// Content type of the part. Files are application/octet-stream by
+	// default.
+	ContentType string
+	// Additional headers of the part.
+	Header http.Header
+	// Content of the part.
+	io.Reader
+}
+
+// JSONPart returns a part with the JSON encoding of v.
+func JSONPart(name string, v interface{}) (Part, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{
+		Name:        name,
+		ContentType: jsonContentType,
+		Reader:      bytes.NewReader(buf),
+	}, nil
+}
+
+// header returns the headers of the part within a message of the given
+// multipart subtype.
+func (self Part) header(subtype string) textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+
+	for k, v := range self.Header {
+		header[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+
+	if subtype == MultipartFormData {
+		if self.Name != "" || self.FileName != "" {
+			disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(self.Name))
+			if self.FileName != "" {
+				disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(self.FileName))
+			}
+			header.Set("Content-Disposition", disposition)
+		}
+	} else if self.FileName != "" {
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, quoteEscaper.Replace(self.FileName)))
+	}
+
+	if self.ContentType != "" {
+		header.Set("Content-Type", self.ContentType)
+	} else if self.FileName != "" && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+
+	return header
+}
+
+// MultipartMessage struct for multipart requests, you can't generate a
+// MultipartMessage directly, use rest.NewMultipartMessage(),
+// rest.NewMultipartParts() or a MultipartBuilder instead.
+//
+// The readers of the parts are not read until the message is sent, and then
+// they're streamed instead of being held in memory. When the size of every
+// part is known (the reader has a Len method or is an io.Seeker, like *os.File
+// or *bytes.Reader) the Content-Length of the request is set. Messages whose
+// readers are all io.Seekers are rewound to be sent again, on retries or in
+// other requests, other messages can only be sent once. A message must not be
+// sent by concurrent requests.
+type MultipartMessage struct {
+	contentType string
+	boundary    string
+	parts       []*multipartPart
+	// Set once a message that can't be rewound was sent.
+	sent bool
+}
+
+// multipartPart is a part of a MultipartMessage.
+type multipartPart struct {
+	header textproto.MIMEHeader
+	r      io.Reader
+	// Length of the content, -1 if unknown.
+	size int64
+	// Offset r is rewound to before sending the part again, -1 if r can't be
+	// rewound.
+	offset int64
+}
+
+func newMultipartPart(header textproto.MIMEHeader, r io.Reader) *multipartPart {
+	if r == nil {
+		r = strings.NewReader("")
+	}
+
+	part := &multipartPart{header: header, r: r, size: -1, offset: -1}
+
+	if s, ok := r.(io.Seeker); ok {
+		if cur, err := s.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := s.Seek(0, io.SeekEnd); err == nil {
+				if _, err = s.Seek(cur, io.SeekStart); err == nil {
+					part.offset, part.size = cur, end-cur
+				}
+			}
+		}
+	}
+
+	if l, ok := r.(interface{ Len() int }); ok && part.size < 0 {
+		part.size = int64(l.Len())
+	}
+
+	return part
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// NewMultipartMessage creates a *MultipartMessage based on the given parameters.
+// This is useful for PostMultipart() and PutMultipart(). Files come first,
+// then parameters, both sorted by name.
+func NewMultipartMessage(params url.Values, filemap FileMap) (*MultipartMessage, error) {
+	builder := NewMultipartBuilder(MultipartFormData)
+
+	keys := make([]string, 0, len(filemap))
+	for key := range filemap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, file := range filemap[key] {
+			builder.File(key, file)
+		}
+	}
+
+	keys = keys[:0]
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range params[key] {
+			builder.Field(key, value)
+		}
+	}
+
+	return builder.Build()
+}
+
+// NewMultipartParts creates a multipart/form-data *MultipartMessage with the
+// given parts, in the same order.
+func NewMultipartParts(parts ...Part) (*MultipartMessage, error) {
+	builder := NewMultipartBuilder(MultipartFormData)
+
+	for _, part := range parts {
+		builder.Part(part)
+	}
+
+	return builder.Build()
+}
+
+// Multipart subtypes supported by MultipartBuilder.
+const (
+	MultipartFormData = "form-data"
+	MultipartRelated  = "related"
+	MultipartMixed    = "mixed"
+)
+
+// MultipartBuilder creates a *MultipartMessage part by part, parts are sent in
+// the order they're added:
+//
+//	message, err := rest.NewMultipartBuilder(rest.MultipartRelated).
+//		Root(metadata).
+//		Part(rest.Part{ContentType: "image/png", Reader: image}).
+//		Build()
+//
+// Errors are kept until Build returns them.
+type MultipartBuilder struct {
+	subtype  string
+	boundary string
+	root     *Part
+	parts    []Part
+	err      error
+}
+
+// NewMultipartBuilder returns a builder for a message of the given subtype:
+// MultipartFormData, MultipartRelated or MultipartMixed.
+func NewMultipartBuilder(subtype string) *MultipartBuilder {
+	return &MultipartBuilder{
+		subtype:  subtype,
+		boundary: multipart.NewWriter(nil).Boundary(),
+	}
+}
+
+// Boundary sets the boundary between parts instead of a random one, which is
+// useful to compare messages in tests.
+func (self *MultipartBuilder) Boundary(boundary string) *MultipartBuilder {
+	if err := multipart.NewWriter(nil).SetBoundary(boundary); err != nil && self.err == nil {
+		self.err = err
+	}
+	self.boundary = boundary
+	return self
+}
+
+// Field adds a form field.
+func (self *MultipartBuilder) Field(name string, value string) *MultipartBuilder {
+	return self.Part(Part{Name: name, Reader: strings.NewReader(value)})
+}
+
+// File adds a file, the base of its name is used as file name.
+func (self *MultipartBuilder) File(name string, file File) *MultipartBuilder {
+	return self.Part(Part{Name: name, FileName: path.Base(file.Name), Reader: file.Reader})
+}
+
+// JSON adds a part with the JSON encoding of v.
+func (self *MultipartBuilder) JSON(name string, v interface{}) *MultipartBuilder {
+	part, err := JSONPart(name, v)
+	if err != nil {
+		if self.err == nil {
+			self.err = err
+		}
+		return self
+	}
+	return self.Part(part)
+}
+
+// Part adds a part.
+func (self *MultipartBuilder) Part(part Part) *MultipartBuilder {
+	self.parts = append(self.parts, part)
+	return self
+}
+
+// Root sets the root part of a multipart/related message, which is sent first
+// and whose content type becomes the type parameter of the message.
+func (self *MultipartBuilder) Root(part Part) *MultipartBuilder {
+	self.root = &part
+	return self
+}
+
+// Build returns the message.
+func (self *MultipartBuilder) Build() (*MultipartMessage, error) {
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	switch self.subtype {
+	case MultipartFormData, MultipartRelated, MultipartMixed:
+	default:
+		return nil, fmt.Errorf(ErrUnsupportedMultipart.Error(), self.subtype)
+	}
+
+	if self.root != nil && self.subtype != MultipartRelated {
+		return nil, fmt.Errorf(ErrUnsupportedMultipart.Error(), self.subtype+" with a root part")
+	}
+
+	parts := self.parts
+	params := map[string]string{"boundary": self.boundary}
+
+	if self.root != nil {
+		parts = append([]Part{*self.root}, parts...)
+		if ct := self.root.header(self.subtype).Get("Content-Type"); ct != "" {
+			params["type"] = mediaType(ct)
+		}
+	}
+
+	message := &MultipartMessage{
+		contentType: mime.FormatMediaType("multipart/"+self.subtype, params),
+		boundary:    self.boundary,
+		parts:       make([]*multipartPart, 0, len(parts)),
+	}
+
+	for _, part := range parts {
+		message.parts = append(message.parts, newMultipartPart(part.header(self.subtype), part.Reader))
+	}
+
+	return message, nil
+}
+
+// writer returns a multipart writer that uses the boundary of the message.
+func (self *MultipartMessage) writer(w io.Writer) *multipart.Writer {
+	mw := multipart.NewWriter(w)
+	mw.SetBoundary(self.boundary)
+	return mw
+}
+
+// size returns the length of the encoded message, -1 if unknown.
+func (self *MultipartMessage) size() int64 {
+	var n int64
+
+	for _, part := range self.parts {
+		if part.size < 0 {
+			return -1
+		}
+		n += part.size
+	}
+
+	// What surrounds the contents of the parts doesn't depend on them.
+	var framing countingWriter
+
+	mw := self.writer(&framing)
+	for _, part := range self.parts {
+		mw.CreatePart(part.header)
+	}
+	mw.Close()
+
+	return n + int64(framing)
+}
+
+func (self *MultipartMessage) replayable() bool {
+	for _, part := range self.parts {
+		if part.offset < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTo writes the encoded message into w, reading the parts from their
+// starting offsets.
+func (self *MultipartMessage) writeTo(w io.Writer) error {
+	mw := self.writer(w)
+
+	for _, part := range self.parts {
+		if part.offset >= 0 {
+			if _, err := part.r.(io.Seeker).Seek(part.offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		pw, err := mw.CreatePart(part.header)
+		if err != nil {
+			return err
+		}
+
+		if _, err = io.Copy(pw, part.r); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// body returns a request body that streams the message.
+func (self *MultipartMessage) body() *requestBody {
+	var mu sync.Mutex
+	var prev *io.PipeReader
+	var done chan struct{}
+
+	replayable := self.replayable()
+
+	return &requestBody{
+		contentType: self.contentType,
+		size:        self.size(),
+		oneShot:     !replayable,
+		open: func() (io.ReadCloser, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !replayable {
+				if self.sent {
+					return nil, ErrBodyNotReplayable
+				}
+				self.sent = true
+			}
+
+			// The previous copy is no longer needed, the parts can be
+			// rewound once it stopped reading them.
+			if prev != nil {
+				prev.CloseWithError(ErrBodyNotReplayable)
+				<-done
+			}
+
+			pr, pw := io.Pipe()
+			prev, done = pr, make(chan struct{})
+
+			go func(done chan struct{}) {
+				defer close(done)
+				// Writing stops as soon as the transport closes the reader,
+				// errors of the parts are seen by the transport.
+				pw.CloseWithError(self.writeTo(pw))
+			}(done)
+
+			return pr, nil
+		},
+	}
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int64
+
+func (self *countingWriter) Write(p []byte) (int, error) {
+	*self += countingWriter(len(p))
+	return len(p), nil
+}
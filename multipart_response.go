@@ -0,0 +1,205 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MultipartResponse reads the parts of a multipart response, like the
+// multipart/mixed responses of batch APIs or the multipart/byteranges
+// responses to range requests. Give a pointer to a MultipartResponse as
+// destination and iterate over the parts:
+//
+//	var res rest.MultipartResponse
+//	if err := client.Get(&res, "/batch", nil); err != nil {
+//		...
+//	}
+//	defer res.Close()
+//
+//	for res.Next() {
+//		part := res.Part()
+//		...
+//	}
+//
+//	if err := res.Err(); err != nil {
+//		...
+//	}
+//
+// Responses that are not multipart are seen as a single part with the headers
+// of the response. Like io.ReadCloser destinations, the response body must be
+// closed with Close.
+type MultipartResponse struct {
+	// Content-Type of the response.
+	ContentType string
+
+	client *Client
+	body   io.ReadCloser
+	reader *multipart.Reader
+	single *ResponsePart
+	part   *ResponsePart
+	err    error
+}
+
+// ResponsePart is a part of a MultipartResponse, its content can be read until
+// the next part is requested.
+type ResponsePart struct {
+	Header textproto.MIMEHeader
+	io.Reader
+
+	client *Client
+}
+
+func newMultipartResponse(client *Client, res *http.Response, body io.ReadCloser) (*MultipartResponse, error) {
+	contentType := res.Header.Get("Content-Type")
+
+	self := &MultipartResponse{
+		ContentType: contentType,
+		client:      client,
+		body:        body,
+	}
+
+	mt, params, err := mime.ParseMediaType(contentType)
+
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		header := textproto.MIMEHeader{}
+		for _, k := range []string{"Content-Type", "Content-Range", "Content-Disposition", "Content-Id"} {
+			if v, ok := res.Header[k]; ok {
+				header[k] = v
+			}
+		}
+		self.single = &ResponsePart{Header: header, Reader: body, client: client}
+		return self, nil
+	}
+
+	if params["boundary"] == "" {
+		return nil, ErrMissingBoundary
+	}
+
+	self.reader = multipart.NewReader(body, params["boundary"])
+
+	return self, nil
+}
+
+// Next advances to the next part, it returns false when there are no more
+// parts or an error happened.
+func (self *MultipartResponse) Next() bool {
+	self.part = nil
+
+	if self.err != nil || self.body == nil {
+		return false
+	}
+
+	if self.reader == nil {
+		self.part, self.single = self.single, nil
+		return self.part != nil
+	}
+
+	p, err := self.reader.NextPart()
+	if err != nil {
+		if err != io.EOF {
+			self.err = err
+		}
+		return false
+	}
+
+	self.part = &ResponsePart{Header: p.Header, Reader: p, client: self.client}
+
+	return true
+}
+
+// Part returns the current part.
+func (self *MultipartResponse) Part() *ResponsePart {
+	return self.part
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (self *MultipartResponse) Err() error {
+	return self.err
+}
+
+// Close closes the response body, it's safe to call it more than once.
+func (self *MultipartResponse) Close() error {
+	if self.body == nil {
+		return nil
+	}
+	body := self.body
+	self.body, self.part = nil, nil
+	return body.Close()
+}
+
+// DecodeAll decodes each of the remaining parts into a new element appended to
+// the slice dst points to, see ResponsePart.Decode.
+func (self *MultipartResponse) DecodeAll(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf(ErrCouldNotConvert.Error(), "parts", reflect.TypeOf(dst))
+	}
+
+	slice := rv.Elem()
+
+	for self.Next() {
+		elem := reflect.New(slice.Type().Elem())
+		if err := self.part.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+
+	return self.Err()
+}
+
+// Decode converts the content of the part into dst, like a response body of
+// the same content type would be.
+func (self *ResponsePart) Decode(dst interface{}) error {
+	buf, err := ioutil.ReadAll(self)
+	if err != nil {
+		return err
+	}
+	return self.client.convert(dst, self.Header.Get("Content-Type"), buf)
+}
+
+// Range returns the byte range of the part as given by its Content-Range
+// header, size is -1 when the length of the whole resource is unknown. ok is
+// false when there's no valid byte range.
+func (self *ResponsePart) Range() (first int64, last int64, size int64, ok bool) {
+	value := strings.TrimSpace(self.Header.Get("Content-Range"))
+
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, 0, false
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(value[len("bytes "):]), "/", 2)
+	bounds := strings.SplitN(parts[0], "-", 2)
+
+	if len(parts) != 2 || len(bounds) != 2 {
+		return 0, 0, 0, false
+	}
+
+	var err error
+
+	if first, err = strconv.ParseInt(bounds[0], 10, 64); err != nil || first < 0 {
+		return 0, 0, 0, false
+	}
+	if last, err = strconv.ParseInt(bounds[1], 10, 64); err != nil || last < first {
+		return 0, 0, 0, false
+	}
+
+	if parts[1] == "*" {
+		return first, last, -1, true
+	}
+
+	if size, err = strconv.ParseInt(parts[1], 10, 64); err != nil || size <= last {
+		return 0, 0, 0, false
+	}
+
+	return first, last, size, true
+}
@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMultipartResponse(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/batch":
+			mw := multipart.NewWriter(w)
+			w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+			for _, body := range []string{`{"id": 1, "name": "a"}`, `{"id": 2, "name": "b"}`} {
+				pw, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+				pw.Write([]byte(body))
+			}
+			mw.Close()
+		case "/ranges":
+			mw := multipart.NewWriter(w)
+			w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+			w.WriteHeader(http.StatusPartialContent)
+			for _, r := range []string{"bytes 0-4/20", "bytes 10-14/20"} {
+				pw, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain"}, "Content-Range": {r}})
+				pw.Write([]byte("hello"))
+			}
+			mw.Close()
+		case "/single":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Range", "bytes 5-9/*")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("world"))
+		case "/broken":
+			w.Header().Set("Content-Type", "multipart/mixed")
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &trackingTransport{RoundTripper: newTransport()}
+	client.Transport = transport
+
+	var res MultipartResponse
+
+	if err = client.Get(&res, "/batch", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var values []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	if err = res.DecodeAll(&values); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 2 || values[0].ID != 1 || values[1].Name != "b" {
+		t.Fatalf("Unexpected values %#v.", values)
+	}
+
+	if atomic.LoadInt32(&transport.open) != 1 {
+		t.Fatalf("Expecting the body to be left open.")
+	}
+
+	res.Close()
+
+	if atomic.LoadInt32(&transport.open) != 0 {
+		t.Fatalf("Expecting the body to be closed.")
+	}
+
+	// Byte ranges.
+	for path, expected := range map[string][][3]int64{
+		"/ranges": {{0, 4, 20}, {10, 14, 20}},
+		"/single": {{5, 9, -1}},
+	} {
+		if err = client.Get(&res, path, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		var i int
+		for ; res.Next(); i++ {
+			part := res.Part()
+
+			first, last, size, ok := part.Range()
+			if !ok || i >= len(expected) || [3]int64{first, last, size} != expected[i] {
+				t.Fatalf("%s: unexpected range %d-%d/%d in part %d.", path, first, last, size, i)
+			}
+
+			buf, err := io.ReadAll(part)
+			if err != nil || len(buf) != 5 {
+				t.Fatalf("%s: unexpected content %q (%v).", path, buf, err)
+			}
+		}
+
+		if res.Err() != nil || i != len(expected) {
+			t.Fatalf("%s: expecting %d parts, got %d (%v).", path, len(expected), i, res.Err())
+		}
+
+		res.Close()
+	}
+
+	if err = client.Get(&res, "/broken", nil); err != ErrMissingBoundary {
+		t.Fatalf("Expecting ErrMissingBoundary, got %v.", err)
+	}
+
+	if atomic.LoadInt32(&transport.open) != 0 {
+		t.Fatalf("Expecting all bodies to be closed.")
+	}
+}
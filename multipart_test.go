@@ -0,0 +1,352 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// zeroReader reads zeros forever.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+type failingReader struct {
+	err error
+}
+
+func (self failingReader) Read(p []byte) (int, error) {
+	return 0, self.err
+}
+
+func TestStreamingMultipart(t *testing.T) {
+	var err error
+
+	type received struct {
+		Length int64
+		Fields map[string][]string
+		Files  map[string]int64
+	}
+
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" {
+			if attempts++; attempts == 1 {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		res := received{
+			Length: r.ContentLength,
+			Fields: map[string][]string{},
+			Files:  map[string]int64{},
+		}
+
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if part.FileName() != "" {
+				n, _ := io.Copy(io.Discard, part)
+				res.Files[part.FileName()] = n
+			} else {
+				buf, _ := io.ReadAll(part)
+				res.Fields[part.FormName()] = append(res.Fields[part.FormName()], string(buf))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sizes are known, so is the length of the request.
+	message, err := NewMultipartMessage(url.Values{"foo": {"bar", "baz"}}, FileMap{
+		"file": {
+			{"a.txt", strings.NewReader("hello")},
+			{"dir/b.bin", bytes.NewReader(make([]byte, 1000))},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res received
+	if err = client.PostMultipart(&res, "/", message); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Length <= 1005 || res.Files["a.txt"] != 5 || res.Files["b.bin"] != 1000 || strings.Join(res.Fields["foo"], ",") != "bar,baz" {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	// Seekable parts are rewound for retries and later requests.
+	client.RetryPolicy = &Backoff{MinDelay: 1, Methods: []string{"POST"}}
+
+	if err = client.PostMultipart(&res, "/flaky", message); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 || res.Files["a.txt"] != 5 || res.Files["b.bin"] != 1000 {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	// Unknown sizes are sent chunked, without holding them in memory.
+	const size = 32 << 20
+
+	message, err = NewMultipartMessage(nil, FileMap{
+		"file": {{"zeros", io.LimitReader(zeroReader{}, size)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	if err = client.PutMultipart(&res, "/", message); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if res.Length != -1 || res.Files["zeros"] != size {
+		t.Fatalf("Unexpected request %#v.", res)
+	}
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/2 {
+		t.Fatalf("Expecting the parts to be streamed, %d bytes were allocated.", allocated)
+	}
+
+	// Such messages can't be sent twice.
+	if err = client.PutMultipart(&res, "/", message); !errors.Is(err, ErrBodyNotReplayable) {
+		t.Fatalf("Expecting ErrBodyNotReplayable, got %v.", err)
+	}
+
+	// Errors of the parts reach the caller.
+	errRead := errors.New("read failed")
+
+	message, err = NewMultipartMessage(nil, FileMap{
+		"file": {{"broken", io.MultiReader(strings.NewReader("partial"), failingReader{errRead})}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = client.R().Multipart(message).Into(&res).Post(context.Background(), "/"); !errors.Is(err, errRead) {
+		t.Fatalf("Expecting the error of the part, got %v.", err)
+	}
+}
+
+func TestMultipartParts(t *testing.T) {
+	var err error
+
+	type part struct {
+		Name        string
+		FileName    string
+		ContentType string
+		Extra       string
+		Body        string
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var parts []part
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			buf, _ := io.ReadAll(p)
+			parts = append(parts, part{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), p.Header.Get("X-Extra"), string(buf)})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parts)
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := JSONPart("metadata", map[string]string{"title": "Report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := NewMultipartParts(
+		metadata,
+		Part{
+			Name:        "file",
+			FileName:    "report 2024.csv",
+			ContentType: "text/csv",
+			Header:      http.Header{"X-Extra": {"1"}, "Content-Type": {"text/plain"}},
+			Reader:      strings.NewReader("a,b\n"),
+		},
+		Part{Name: `say "hi"`, Reader: strings.NewReader("hi")},
+		Part{Name: "blob", FileName: "blob.bin", Reader: strings.NewReader("...")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []part
+	if err = client.PostMultipart(&parts, "/", message); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []part{
+		{"metadata", "", jsonContentType, "", "{\"title\":\"Report\"}"},
+		{"file", "report 2024.csv", "text/csv", "1", "a,b\n"},
+		{`say "hi"`, "", "", "", "hi"},
+		{"blob", "blob.bin", "application/octet-stream", "", "..."},
+	}
+
+	if len(parts) != len(expected) {
+		t.Fatalf("Unexpected parts %#v.", parts)
+	}
+
+	for i := range expected {
+		if parts[i] != expected[i] {
+			t.Fatalf("Part %d: expecting %#v, got %#v.", i, expected[i], parts[i])
+		}
+	}
+}
+
+func TestMultipartBuilder(t *testing.T) {
+	var err error
+
+	encode := func(message *MultipartMessage) string {
+		r, err := message.body().open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+
+	message, err := NewMultipartBuilder(MultipartRelated).
+		Boundary("b").
+		Part(Part{ContentType: "text/plain", FileName: "notes.txt", Reader: strings.NewReader("hello")}).
+		Root(Part{ContentType: jsonContentType, Reader: strings.NewReader(`{"name":"notes.txt"}`)}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.contentType != `multipart/related; boundary=b; type="application/json"` {
+		t.Fatalf("Unexpected content type %q.", message.contentType)
+	}
+
+	expected := "--b\r\n" +
+		"Content-Type: application/json; charset=utf-8\r\n\r\n" +
+		"{\"name\":\"notes.txt\"}\r\n" +
+		"--b\r\n" +
+		"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"hello\r\n" +
+		"--b--\r\n"
+
+	if body := encode(message); body != expected {
+		t.Fatalf("Unexpected message:\n%q\nexpecting:\n%q", body, expected)
+	}
+
+	if message.body().size != int64(len(expected)) {
+		t.Fatalf("Expecting size %d, got %d.", len(expected), message.body().size)
+	}
+
+	// Parts keep the order they were added in.
+	message, err = NewMultipartBuilder(MultipartFormData).
+		Boundary("b").
+		JSON("metadata", map[string]int{"z": 1}).
+		Field("b", "2").
+		Field("a", "1").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "--b\r\n" +
+		"Content-Disposition: form-data; name=\"metadata\"\r\n" +
+		"Content-Type: application/json; charset=utf-8\r\n\r\n" +
+		"{\"z\":1}\r\n" +
+		"--b\r\n" +
+		"Content-Disposition: form-data; name=\"b\"\r\n\r\n" +
+		"2\r\n" +
+		"--b\r\n" +
+		"Content-Disposition: form-data; name=\"a\"\r\n\r\n" +
+		"1\r\n" +
+		"--b--\r\n"
+
+	if body := encode(message); body != expected || message.contentType != "multipart/form-data; boundary=b" {
+		t.Fatalf("Unexpected message %q:\n%q\nexpecting:\n%q", message.contentType, body, expected)
+	}
+
+	message, err = NewMultipartBuilder(MultipartMixed).
+		Part(Part{ContentType: "text/plain", Reader: strings.NewReader("one")}).
+		Part(Part{ContentType: "text/html", Reader: strings.NewReader("<p>two</p>")}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(message.contentType, "multipart/mixed; boundary=") {
+		t.Fatalf("Unexpected content type %q.", message.contentType)
+	}
+
+	if _, err = NewMultipartBuilder(MultipartFormData).Boundary("not a valid boundary ").Build(); err == nil {
+		t.Fatalf("Expecting an error for an invalid boundary.")
+	}
+
+	if _, err = NewMultipartBuilder("alternative").Build(); err == nil {
+		t.Fatalf("Expecting an error for an unsupported subtype.")
+	}
+
+	if _, err = NewMultipartBuilder(MultipartMixed).Root(Part{}).Build(); err == nil {
+		t.Fatalf("Expecting an error for a root part outside multipart/related.")
+	}
+}
@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+)
+
+const ndjsonMediaType = `application/x-ndjson`
+
+// NDJSONStream iterates over the values of a newline delimited JSON response
+// (NDJSON, also known as JSON Lines), reading them from the connection as
+// they arrive:
+//
+//	stream, err := client.GetNDJSON(ctx, "/logs", nil)
+//	if err != nil {
+//		...
+//	}
+//	defer stream.Close()
+//
+//	for stream.Next() {
+//		var entry LogEntry
+//		if err := stream.Decode(&entry); err != nil {
+//			...
+//		}
+//	}
+//
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+//
+// The response body is closed once all the values were read, or when Close is
+// called, whichever happens first.
+type NDJSONStream struct {
+	client *Client
+	body   io.ReadCloser
+	r      *bufio.Reader
+	line   []byte
+	err    error
+	closed bool
+}
+
+// Next advances the stream to the next value, it returns false when there are
+// no more values or an error happened.
+func (self *NDJSONStream) Next() bool {
+	for !self.closed {
+		line, err := self.r.ReadBytes('\n')
+
+		// Blank lines are ignored.
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			self.line = line
+			if err != nil && err != io.EOF {
+				self.fail(err)
+				return false
+			}
+			return true
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				self.fail(err)
+			} else {
+				self.Close()
+			}
+			return false
+		}
+	}
+
+	return false
+}
+
+// Decode decodes the current value into v, using the client's JSON decoder.
+func (self *NDJSONStream) Decode(v interface{}) error {
+	if self.line == nil {
+		return io.EOF
+	}
+
+	decoder := self.client.decoder("application/json")
+	if decoder == nil {
+		decoder = JSONDecoder{}
+	}
+
+	return decode(decoder, bytes.NewReader(self.line), "application/json", v)
+}
+
+// Bytes returns the raw current value. The slice is only valid until the next
+// call to Next.
+func (self *NDJSONStream) Bytes() []byte {
+	return self.line
+}
+
+// Err returns the error that stopped the stream, if any.
+func (self *NDJSONStream) Err() error {
+	return self.err
+}
+
+// Close stops the stream and closes the response body, it's safe to call it
+// more than once.
+func (self *NDJSONStream) Close() error {
+	if self.closed {
+		return nil
+	}
+
+	self.closed = true
+	self.line = nil
+
+	return self.body.Close()
+}
+
+func (self *NDJSONStream) fail(err error) {
+	self.err = err
+	self.Close()
+}
+
+// NDJSON sends the request with the given method and returns a stream over
+// the values of its newline delimited JSON response. An Accept header is added
+// unless the request already has one.
+func (self *Request) NDJSON(ctx context.Context, method string, path string) (*NDJSONStream, error) {
+	var body io.ReadCloser
+
+	if self.header.Get("Accept") == "" {
+		self.Header("Accept", ndjsonMediaType)
+	}
+
+	if err := self.Into(&body).Send(ctx, method, path); err != nil {
+		return nil, err
+	}
+
+	return &NDJSONStream{
+		client: self.client,
+		body:   body,
+		r:      bufio.NewReader(body),
+	}, nil
+}
+
+// GetNDJSON performs a HTTP GET request and returns a stream over the values
+// of its newline delimited JSON response.
+func (self *Client) GetNDJSON(ctx context.Context, path string, data url.Values) (*NDJSONStream, error) {
+	return self.R().QueryValues(data).NDJSON(ctx, "GET", path)
+}
@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNDJSON(t *testing.T) {
+	var err error
+
+	done := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != ndjsonMediaType {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+
+		w.Header().Set("Content-Type", ndjsonMediaType)
+
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, "{\"n\": 1}\n{\"n\": 2}\n")
+			gz.Close()
+		case "/endless":
+			defer close(done)
+			for i := 0; ; i++ {
+				if _, err := fmt.Fprintf(w, "{\"n\": %d}\n", i); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+		default:
+			fmt.Fprintf(w, "{\"n\": 1}\n\n{\"n\": 2}\r\n{\"n\": 3}")
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(path string) []int {
+		stream, err := client.GetNDJSON(context.Background(), path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.Close()
+
+		var values []int
+		for stream.Next() {
+			var v struct {
+				N int `json:"n"`
+			}
+			if err := stream.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, v.N)
+		}
+
+		if err := stream.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		return values
+	}
+
+	if values := collect("/"); fmt.Sprint(values) != "[1 2 3]" {
+		t.Fatalf("Unexpected values %v.", values)
+	}
+
+	client.Header.Set("Accept-Encoding", "gzip")
+
+	if values := collect("/gzip"); fmt.Sprint(values) != "[1 2]" {
+		t.Fatalf("Unexpected values %v.", values)
+	}
+
+	// Stopping early releases the connection.
+	stream, err := client.GetNDJSON(context.Background(), "/endless", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3 && stream.Next(); i++ {
+	}
+
+	stream.Close()
+
+	if stream.Next() {
+		t.Fatalf("Expecting closed stream.")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Server is still writing.")
+	}
+
+	// Rejected responses are reported before iterating.
+	client.StatusPolicy = RequireSuccess
+
+	_, err = client.R().Header("Accept", "text/plain").NDJSON(context.Background(), "GET", "/")
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotAcceptable {
+		t.Fatalf("Expecting *StatusError, got %v.", err)
+	}
+}
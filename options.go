@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const callOptionsKey contextKey = iota
+
+// callOptions holds settings that apply to a single call, they travel within
+// the request's context.
+type callOptions struct {
+	errorDst    interface{}
+	timeouts    Timeouts
+	middleware  []Middleware
+	compression Compression
+	// Zero means the client's limit.
+	maxResponseBytes int64
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
+}
+
+func optionsFrom(ctx context.Context) callOptions {
+	if opts, ok := ctx.Value(callOptionsKey).(callOptions); ok {
+		return opts
+	}
+	return callOptions{}
+}
+
+func withOptions(ctx context.Context, fn func(*callOptions)) context.Context {
+	opts := optionsFrom(ctx)
+	fn(&opts)
+	return context.WithValue(ctx, callOptionsKey, opts)
+}
+
+// WithErrorDestination returns a copy of ctx that makes the request decode
+// rejected responses into dst (a pointer to a struct, map, string or []byte
+// array) instead of the regular destination. The decoded value is also
+// available as the Value of the returned *StatusError.
+func WithErrorDestination(ctx context.Context, dst interface{}) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.errorDst = dst
+	})
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const problemMediaType = `application/problem+json`
+
+// ProblemDetails represents an application/problem+json body, as described by
+// RFC 9457 (which obsoletes RFC 7807). Responses with that content type are
+// errors whatever the client's StatusPolicy says: they are returned as a
+// *StatusError wrapping a *ProblemDetails, or as a bare *ProblemDetails when
+// the status is a successful 2xx one that the error could not be about. Use
+// errors.As to retrieve it in both cases. Error destinations and ErrorFactory
+// don't change that, rejected documents are decoded into the value they
+// provide as well, which becomes the Value of the *StatusError.
+type ProblemDetails struct {
+	// URI reference that identifies the problem type, "about:blank" when the
+	// server didn't provide one.
+	Type string `json:"type"`
+	// Short, human-readable summary of the problem type.
+	Title string `json:"title,omitempty"`
+	// HTTP status code generated by the origin server.
+	Status int `json:"status,omitempty"`
+	// Human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// URI reference that identifies this occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// Any other member of the problem object.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func (self *ProblemDetails) Error() string {
+	title := self.Title
+	if title == "" {
+		title = self.Type
+	}
+	if self.Detail == "" {
+		return fmt.Sprintf(`Problem: %s.`, title)
+	}
+	return fmt.Sprintf(`Problem: %s: %s.`, title, self.Detail)
+}
+
+// UnmarshalJSON decodes a problem object, members that are not part of the
+// standard set are stored in Extensions.
+func (self *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*self = ProblemDetails{Type: "about:blank"}
+
+	// Members with a value of the wrong type must be ignored, as mandated by
+	// the RFC.
+	for key, raw := range members {
+		switch key {
+		case "type":
+			var s string
+			if json.Unmarshal(raw, &s) == nil && s != "" {
+				self.Type = s
+			}
+		case "title":
+			json.Unmarshal(raw, &self.Title)
+		case "status":
+			json.Unmarshal(raw, &self.Status)
+		case "detail":
+			json.Unmarshal(raw, &self.Detail)
+		case "instance":
+			json.Unmarshal(raw, &self.Instance)
+		default:
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			if self.Extensions == nil {
+				self.Extensions = map[string]interface{}{}
+			}
+			self.Extensions[key] = v
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the problem object, extension members included.
+func (self ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+
+	for key, value := range self.Extensions {
+		members[key] = value
+	}
+
+	members["type"] = self.Type
+	if self.Title != "" {
+		members["title"] = self.Title
+	}
+	if self.Status != 0 {
+		members["status"] = self.Status
+	}
+	if self.Detail != "" {
+		members["detail"] = self.Detail
+	}
+	if self.Instance != "" {
+		members["instance"] = self.Instance
+	}
+
+	return json.Marshal(members)
+}
@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Minimum time between two calls of a ProgressFunc, besides the last one.
+const progressInterval = 100 * time.Millisecond
+
+// Progress describes how a transfer is going.
+type Progress struct {
+	// Bytes transferred so far.
+	Bytes int64
+	// Bytes to transfer in total, -1 if unknown.
+	Total int64
+	// Average rate of the transfer, in bytes per second.
+	Rate float64
+	// Estimated time left, -1 if unknown.
+	ETA time.Duration
+	// Set in the last call, once everything was transferred.
+	Done bool
+}
+
+// ProgressFunc receives the progress of a transfer, it's called at most every
+// 100ms and once more when the transfer completes. It's called from the
+// goroutine that reads the body, which for request bodies is one of the
+// transport.
+type ProgressFunc func(Progress)
+
+// WithUploadProgress returns a copy of ctx that makes the request report the
+// progress of sending its body to fn. Retries report it from the start again.
+func WithUploadProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.uploadProgress = fn
+	})
+}
+
+// WithDownloadProgress returns a copy of ctx that makes the request report the
+// progress of receiving the response body to fn, which also works when the
+// body is read from an io.ReadCloser destination. The total is unknown for
+// compressed responses.
+func WithDownloadProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.downloadProgress = fn
+	})
+}
+
+// withUploadProgress returns a body that reports the progress of reading it
+// to fn.
+func withUploadProgress(body *requestBody, fn ProgressFunc) *requestBody {
+	tracked := *body
+	tracked.open = func() (io.ReadCloser, error) {
+		r, err := body.open()
+		if err != nil {
+			return nil, err
+		}
+		return newProgressReader(r, body.size, fn), nil
+	}
+	return &tracked
+}
+
+// progressReader reports the progress of reading a body.
+type progressReader struct {
+	io.ReadCloser
+	fn    ProgressFunc
+	total int64
+	n     int64
+	start time.Time
+	last  time.Time
+	done  bool
+}
+
+func newProgressReader(body io.ReadCloser, total int64, fn ProgressFunc) *progressReader {
+	now := time.Now()
+	return &progressReader{ReadCloser: body, fn: fn, total: total, start: now, last: now}
+}
+
+func (self *progressReader) Read(p []byte) (int, error) {
+	n, err := self.ReadCloser.Read(p)
+	self.n += int64(n)
+
+	now := time.Now()
+
+	if err == io.EOF {
+		if !self.done {
+			self.done = true
+			self.report(now)
+		}
+	} else if n > 0 && now.Sub(self.last) >= progressInterval {
+		self.last = now
+		self.report(now)
+	}
+
+	return n, err
+}
+
+func (self *progressReader) report(now time.Time) {
+	progress := Progress{
+		Bytes: self.n,
+		Total: self.total,
+		ETA:   -1,
+		Done:  self.done,
+	}
+
+	if elapsed := now.Sub(self.start).Seconds(); elapsed > 0 {
+		progress.Rate = float64(self.n) / elapsed
+	}
+
+	switch {
+	case self.done:
+		progress.ETA = 0
+	case self.total >= 0 && progress.Rate > 0:
+		progress.ETA = time.Duration(float64(self.total-self.n) / progress.Rate * float64(time.Second))
+	}
+
+	self.fn(progress)
+}
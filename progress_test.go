@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	var err error
+
+	const size = 64 << 10
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write(make([]byte, size))
+			gz.Close()
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.(http.Flusher).Flush()
+
+		// Slow enough to see intermediate reports.
+		for i := 0; i < 4; i++ {
+			w.Write(make([]byte, size/4))
+			w.(http.Flusher).Flush()
+			time.Sleep(progressInterval)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var uploads, downloads []Progress
+
+	record := func(dst *[]Progress) ProgressFunc {
+		return func(p Progress) {
+			mu.Lock()
+			*dst = append(*dst, p)
+			mu.Unlock()
+		}
+	}
+
+	var buf []byte
+	err = client.R().
+		Body("application/octet-stream", make([]byte, size)).
+		UploadProgress(record(&uploads)).
+		DownloadProgress(record(&downloads)).
+		Into(&buf).
+		Post(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, reports []Progress, total int64) {
+		if len(reports) == 0 {
+			t.Fatalf("%s: no progress reported.", name)
+		}
+
+		last := reports[len(reports)-1]
+		if !last.Done || last.Bytes != size || last.Total != total || last.ETA != 0 || last.Rate <= 0 {
+			t.Fatalf("%s: unexpected last report %#v.", name, last)
+		}
+
+		for i, p := range reports[:len(reports)-1] {
+			if p.Done || p.Bytes > size || (i > 0 && p.Bytes < reports[i-1].Bytes) {
+				t.Fatalf("%s: unexpected report %#v.", name, p)
+			}
+			if total >= 0 && p.ETA < 0 {
+				t.Fatalf("%s: expecting an estimate in %#v.", name, p)
+			}
+		}
+	}
+
+	check("upload", uploads, size)
+	check("download", downloads, size)
+
+	if len(downloads) < 2 {
+		t.Fatalf("Expecting intermediate reports, got %#v.", downloads)
+	}
+
+	// Decompressed bodies have no known total, io.ReadCloser destinations
+	// report while being read.
+	downloads = nil
+
+	var body io.ReadCloser
+	if err = client.R().DownloadProgress(record(&downloads)).Into(&body).Get(context.Background(), "/gzip"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(downloads) != 0 {
+		t.Fatalf("Expecting no reports before reading.")
+	}
+
+	io.Copy(io.Discard, body)
+	body.Close()
+
+	check("gzip", downloads, -1)
+
+	// Context variant.
+	uploads = nil
+
+	ctx := WithUploadProgress(context.Background(), record(&uploads))
+	if err = client.PutRawContext(ctx, &buf, "/", bytes.Repeat([]byte("a"), size)); err != nil {
+		t.Fatal(err)
+	}
+
+	check("context", uploads, size)
+}
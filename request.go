@@ -0,0 +1,323 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Request is a request being built, use Client.R() to create one. Its methods
+// set the different parts of the request and return the same *Request, so
+// calls can be chained:
+//
+//	err := client.R().
+//		Header("X-Request-Id", id).
+//		Query("dry_run", "true").
+//		JSON(thing).
+//		Into(&created).
+//		ErrorInto(&apiErr).
+//		Post(ctx, "/things")
+//
+// A Request is meant to be sent once.
+type Request struct {
+	client  *Client
+	header  http.Header
+	query   url.Values
+	body    *requestBody
+	dst     interface{}
+	options []func(*callOptions)
+	err     error
+}
+
+// R creates a new request for the client.
+func (self *Client) R() *Request {
+	return &Request{
+		client: self,
+		header: http.Header{},
+		query:  url.Values{},
+	}
+}
+
+// Header sets a header for this request only, it takes precedence over the
+// client's headers.
+func (self *Request) Header(key string, value string) *Request {
+	self.header.Set(key, value)
+	return self
+}
+
+// Query adds values for the given query parameter.
+func (self *Request) Query(key string, values ...string) *Request {
+	for _, value := range values {
+		self.query.Add(key, value)
+	}
+	return self
+}
+
+// QueryValues adds all the given query parameters.
+func (self *Request) QueryValues(data url.Values) *Request {
+	for key, values := range data {
+		self.Query(key, values...)
+	}
+	return self
+}
+
+// Form sets a application/x-www-form-urlencoded body. A nil data means no
+// body.
+func (self *Request) Form(data url.Values) *Request {
+	self.body = nil
+	if data != nil {
+		self.body = newBytesBody("", []byte(data.Encode()))
+	}
+	return self
+}
+
+// Body sets a raw body with the given content type. POST and PUT requests
+// without content type default to application/x-www-form-urlencoded, unless
+// the client's headers set one. A nil body means no body.
+func (self *Request) Body(contentType string, body []byte) *Request {
+	self.body = nil
+	if body != nil {
+		self.body = newBytesBody(contentType, body)
+	}
+	return self
+}
+
+// BodyReader sets a body that is read from r while the request is sent. Such
+// a body can't be sent again, so the request is never retried. If r is also
+// an io.Closer it's closed once sent, or as soon as Send fails if it's never
+// sent.
+func (self *Request) BodyReader(contentType string, r io.Reader) *Request {
+	rc, ok := r.(io.ReadCloser)
+	if !ok {
+		rc = ioutil.NopCloser(r)
+	}
+
+	var opened bool
+
+	self.body = &requestBody{
+		contentType: contentType,
+		size:        -1,
+		oneShot:     true,
+		open: func() (io.ReadCloser, error) {
+			if opened {
+				return nil, ErrBodyNotReplayable
+			}
+			opened = true
+			return rc, nil
+		},
+		close: func() error {
+			if opened {
+				return nil
+			}
+			opened = true
+			return rc.Close()
+		},
+	}
+	return self
+}
+
+// JSON sets a body with the JSON encoding of v. If the client's StreamJSON
+// is set, v is encoded while the request is sent, see StreamJSON.
+func (self *Request) JSON(v interface{}) *Request {
+	if self.client.StreamJSON {
+		return self.StreamJSON(v)
+	}
+
+	body, err := newJSONBody(v)
+	if err != nil {
+		self.err = err
+		return self
+	}
+
+	self.body = body
+	return self
+}
+
+// StreamJSON sets a body with the JSON encoding of v, which is written
+// straight into the connection instead of being held in memory first. This
+// suits large values, the request is sent without Content-Length and
+// encoding errors are returned when sending it.
+func (self *Request) StreamJSON(v interface{}) *Request {
+	self.body = newJSONStreamBody(v)
+	return self
+}
+
+// XML sets a body with the XML encoding of v, in UTF-8.
+func (self *Request) XML(v interface{}) *Request {
+	return self.XMLCharset(v, "utf-8")
+}
+
+// XMLCharset sets a body with the XML encoding of v, converted into the given
+// charset (see RegisterCharset). The charset is declared in both the XML
+// declaration and the Content-Type header.
+func (self *Request) XMLCharset(v interface{}, charset string) *Request {
+	body, err := newXMLBody(v, charset)
+	if err != nil {
+		self.err = err
+		return self
+	}
+
+	self.body = body
+	return self
+}
+
+// Multipart sets a multipart body, see NewMultipartMessage.
+func (self *Request) Multipart(message *MultipartMessage) *Request {
+	if message == nil {
+		self.err = ErrCouldNotCreateMultipart
+		return self
+	}
+	self.body = message.body()
+	return self
+}
+
+// Into sets the destination the response body is converted into (a pointer to
+// a struct, map, []byte array, string, *bytes.Buffer, io.ReadCloser or
+// Response).
+func (self *Request) Into(dst interface{}) *Request {
+	self.dst = dst
+	return self
+}
+
+// ErrorInto sets the value rejected responses are decoded into, see
+// WithErrorDestination.
+func (self *Request) ErrorInto(dst interface{}) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.errorDst = dst
+	})
+	return self
+}
+
+// Timeout sets the overall time limit for this request.
+func (self *Request) Timeout(d time.Duration) *Request {
+	return self.Timeouts(Timeouts{Total: d})
+}
+
+// Timeouts overrides the client's timeouts for this request, see
+// WithTimeouts.
+func (self *Request) Timeouts(timeouts Timeouts) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.timeouts = opts.timeouts.override(timeouts)
+	})
+	return self
+}
+
+// Compression overrides the client's request body compression for this
+// request, see WithCompression.
+func (self *Request) Compression(compression Compression) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.compression = compression
+	})
+	return self
+}
+
+// MaxResponseBytes overrides the client's limit for the size of the response
+// body for this request, see WithMaxResponseBytes.
+func (self *Request) MaxResponseBytes(n int64) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.maxResponseBytes = n
+	})
+	return self
+}
+
+// UploadProgress reports the progress of sending the request body to fn, see
+// WithUploadProgress.
+func (self *Request) UploadProgress(fn ProgressFunc) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.uploadProgress = fn
+	})
+	return self
+}
+
+// DownloadProgress reports the progress of receiving the response body to fn,
+// see WithDownloadProgress.
+func (self *Request) DownloadProgress(fn ProgressFunc) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.downloadProgress = fn
+	})
+	return self
+}
+
+// Use adds middleware for this request only, they run after the ones of the
+// client.
+func (self *Request) Use(middleware ...Middleware) *Request {
+	self.options = append(self.options, func(opts *callOptions) {
+		opts.middleware = append(opts.middleware[:len(opts.middleware):len(opts.middleware)], middleware...)
+	})
+	return self
+}
+
+// Get sends the request as a HTTP GET.
+func (self *Request) Get(ctx context.Context, path string) error {
+	return self.Send(ctx, "GET", path)
+}
+
+// Post sends the request as a HTTP POST.
+func (self *Request) Post(ctx context.Context, path string) error {
+	return self.Send(ctx, "POST", path)
+}
+
+// Put sends the request as a HTTP PUT.
+func (self *Request) Put(ctx context.Context, path string) error {
+	return self.Send(ctx, "PUT", path)
+}
+
+// Delete sends the request as a HTTP DELETE.
+func (self *Request) Delete(ctx context.Context, path string) error {
+	return self.Send(ctx, "DELETE", path)
+}
+
+// Patch sends the request as a HTTP PATCH.
+func (self *Request) Patch(ctx context.Context, path string) error {
+	return self.Send(ctx, "PATCH", path)
+}
+
+// Head sends the request as a HTTP HEAD.
+func (self *Request) Head(ctx context.Context, path string) error {
+	return self.Send(ctx, "HEAD", path)
+}
+
+// Options sends the request as a HTTP OPTIONS.
+func (self *Request) Options(ctx context.Context, path string) error {
+	return self.Send(ctx, "OPTIONS", path)
+}
+
+// Send sends the request with the given method to the given path, which is
+// added to the client's prefix. When complete, the response body is converted
+// into the destination given to Into.
+func (self *Request) Send(ctx context.Context, method string, path string) error {
+	var addr *url.URL
+	var err error
+
+	if self.err != nil {
+		self.body.release()
+		return self.err
+	}
+
+	if addr, err = url.Parse(self.client.Prefix + strings.TrimLeft(path, "/")); err != nil {
+		self.body.release()
+		return err
+	}
+
+	if len(self.query) > 0 {
+		if addr.RawQuery == "" {
+			addr.RawQuery = self.query.Encode()
+		} else {
+			addr.RawQuery = addr.RawQuery + "&" + self.query.Encode()
+		}
+	}
+
+	if len(self.options) > 0 {
+		ctx = withOptions(ctx, func(opts *callOptions) {
+			for _, fn := range self.options {
+				fn(opts)
+			}
+		})
+	}
+
+	return self.client.newRequest(ctx, self.dst, method, addr, self.header, self.body)
+}
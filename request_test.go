@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Millisecond * 200)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "invalid thing"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"method":       r.Method,
+			"query":        r.URL.RawQuery,
+			"content_type": r.Header.Get("Content-Type"),
+			"custom":       r.Header.Get("X-Custom"),
+			"body":         string(body),
+		})
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.Header.Set("X-Custom", "client")
+	client.Header.Set("Content-Type", "text/plain")
+
+	var res map[string]string
+	var apiErr apiError
+
+	err = client.R().
+		Header("X-Custom", "request").
+		Query("dry_run", "true").
+		JSON(map[string]int{"size": 3}).
+		Into(&res).
+		ErrorInto(&apiErr).
+		Post(context.Background(), "/things?v=1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res["method"] != "POST" || res["query"] != "v=1&dry_run=true" || res["custom"] != "request" {
+		t.Fatalf("Unexpected response %v.", res)
+	}
+
+	if res["content_type"] != "application/json; charset=utf-8" || res["body"] != `{"size":3}` {
+		t.Fatalf("Expecting a JSON body, got %v.", res)
+	}
+
+	// The client's headers are left alone.
+	if client.Header.Get("X-Custom") != "client" || client.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("Client headers were modified.")
+	}
+
+	err = client.R().Query("fail", "1").Into(&res).ErrorInto(&apiErr).Put(context.Background(), "/things")
+
+	if !errors.As(err, new(*StatusError)) || apiErr.Message != "invalid thing" {
+		t.Fatalf("Expecting decoded error, got %v.", err)
+	}
+
+	err = client.R().Timeout(time.Millisecond*50).Get(context.Background(), "/slow")
+
+	if !errors.As(err, new(*TimeoutError)) {
+		t.Fatalf("Expecting *TimeoutError, got %v.", err)
+	}
+
+	if err = client.R().Multipart(nil).Post(context.Background(), "/"); err != ErrCouldNotCreateMultipart {
+		t.Fatalf("Expecting ErrCouldNotCreateMultipart, got %v.", err)
+	}
+}
+
+// closeRecorder counts how many times it's closed.
+type closeRecorder struct {
+	io.Reader
+	closed int32
+}
+
+func (self *closeRecorder) Close() error {
+	atomic.AddInt32(&self.closed, 1)
+	return nil
+}
+
+func TestBodyReaderClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       http.NoBody,
+			}, nil
+		})
+	}
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		path string
+		fail bool
+	}{
+		{"sent", ctx, "/", false},
+		{"invalid URL", ctx, "/%zz", true},
+		{"middleware", WithMiddleware(ctx, answer), "/", false},
+		{"unknown compression", WithCompression(ctx, Compression{Encoding: "nope"}), "/", true},
+	}
+
+	for _, test := range tests {
+		body := &closeRecorder{Reader: strings.NewReader("data")}
+
+		err = client.R().BodyReader("text/plain", body).Send(test.ctx, "POST", test.path)
+
+		if (err != nil) != test.fail {
+			t.Fatalf("%s: unexpected error %v.", test.name, err)
+		}
+
+		// The transport may close the body after the response is received.
+		for i := 0; i < 100 && atomic.LoadInt32(&body.closed) == 0; i++ {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		if atomic.LoadInt32(&body.closed) == 0 {
+			t.Fatalf("%s: expecting the reader to be closed.", test.name)
+		}
+	}
+}
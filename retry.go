@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides whether a request should be sent again. Retry is called
+// after every attempt with the attempt number (starting at 1) and the
+// outcome of the attempt, it returns how long to wait before the next attempt
+// and whether there should be one at all.
+//
+// Requests with bodies are only retried when the body can be rewound, which is
+// the case for all bodies created by this package.
+type RetryPolicy interface {
+	Retry(attempt int, req *http.Request, res *http.Response, err error) (time.Duration, bool)
+}
+
+// Backoff is a RetryPolicy that waits exponentially longer between attempts.
+// The zero value is ready to use.
+type Backoff struct {
+	// Maximum number of attempts, the first one included. Defaults to 3.
+	MaxAttempts int
+	// Delay before the first retry, doubled on every following attempt.
+	// Defaults to 100ms.
+	MinDelay time.Duration
+	// Upper bound for the delay between attempts. When a server asks for a
+	// longer wait through Retry-After the request is not retried. Defaults to
+	// 30s.
+	MaxDelay time.Duration
+	// Randomization factor between 0 and 1, each delay is picked at random
+	// between delay*(1-Jitter) and delay. Zero disables jitter.
+	Jitter float64
+	// Methods that can be retried, defaults to the idempotent methods GET,
+	// HEAD, OPTIONS, TRACE, PUT and DELETE.
+	Methods []string
+	// Status codes that trigger a retry, defaults to 408, 429, 502, 503 and
+	// 504.
+	StatusCodes []int
+	// Reports whether a request that failed with err should be retried,
+	// defaults to retrying network errors and connections closed by the
+	// server. Cancelled requests are never retried.
+	RetryError func(err error) bool
+}
+
+var (
+	defaultRetryMethods     = []string{"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE"}
+	defaultRetryStatusCodes = []int{
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+)
+
+// Retry implements RetryPolicy.
+func (self *Backoff) Retry(attempt int, req *http.Request, res *http.Response, err error) (time.Duration, bool) {
+	maxAttempts := self.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 3
+	}
+
+	if attempt >= maxAttempts || !self.retryMethod(req.Method) {
+		return 0, false
+	}
+
+	if err != nil {
+		if req.Context().Err() != nil {
+			return 0, false
+		}
+
+		retryError := self.RetryError
+		if retryError == nil {
+			retryError = isTemporaryError
+		}
+
+		if !retryError(err) {
+			return 0, false
+		}
+
+		return self.delay(attempt), true
+	}
+
+	if !self.retryStatus(res.StatusCode) {
+		return 0, false
+	}
+
+	if wait, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+		if wait > self.maxDelay() {
+			return 0, false
+		}
+		return wait, true
+	}
+
+	return self.delay(attempt), true
+}
+
+func (self *Backoff) maxDelay() time.Duration {
+	if self.MaxDelay == 0 {
+		return time.Second * 30
+	}
+	return self.MaxDelay
+}
+
+// delay returns the time to wait after the given attempt.
+func (self *Backoff) delay(attempt int) time.Duration {
+	delay := self.MinDelay
+	if delay == 0 {
+		delay = time.Millisecond * 100
+	}
+
+	maxDelay := self.maxDelay()
+
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay = delay * 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if self.Jitter > 0 {
+		jitter := self.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		if spread := int64(float64(delay) * jitter); spread > 0 {
+			delay = delay - time.Duration(rand.Int63n(spread+1))
+		}
+	}
+
+	return delay
+}
+
+func (self *Backoff) retryMethod(method string) bool {
+	methods := self.Methods
+	if methods == nil {
+		methods = defaultRetryMethods
+	}
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Backoff) retryStatus(statusCode int) bool {
+	statusCodes := self.StatusCodes
+	if statusCodes == nil {
+		statusCodes = defaultRetryStatusCodes
+	}
+	for _, code := range statusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isTemporaryError reports whether err looks like a network failure that
+// might not happen again.
+func isTemporaryError(err error) bool {
+	// http.Client wraps every failure into a *url.Error, which is a net.Error
+	// itself, so the cause is what matters.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter parses the value of a Retry-After header, which may be either a
+// number of seconds or a HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the given duration or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
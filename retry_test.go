@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	var err error
+	var attempts int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("Expecting body to be replayed, got %q.", body)
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.RetryPolicy = &Backoff{MinDelay: time.Millisecond, Jitter: 0.5}
+
+	var buf string
+	if err = client.PutRaw(&buf, "/", []byte("payload")); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf != "done" || attempts != 3 {
+		t.Fatalf("Expecting success after 3 attempts, got %q after %d.", buf, attempts)
+	}
+
+	// POST is not idempotent, it's not retried by default.
+	attempts = 0
+
+	var res Response
+	if err = client.PostRaw(&res, "/", []byte("payload")); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+		t.Fatalf("Expecting a single attempt, got %d.", attempts)
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := srv.URL
+	srv.Close()
+
+	client, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts int
+	client.RetryPolicy = &Backoff{
+		MaxAttempts: 4,
+		MinDelay:    time.Millisecond,
+		RetryError: func(err error) bool {
+			attempts++
+			return isTemporaryError(err)
+		},
+	}
+
+	if err = client.Get(nil, "/", nil); err == nil {
+		t.Fatalf("Expecting an error.")
+	}
+
+	if attempts != 3 {
+		t.Fatalf("Expecting 3 retries, got %d.", attempts)
+	}
+
+	// Failures that would happen again, like a certificate that can't be
+	// verified, are not retried.
+	srv = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	policy := client.RetryPolicy
+
+	if client, err = New(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	client.RetryPolicy = policy
+	attempts = 0
+
+	if err = client.Get(nil, "/", nil); err == nil {
+		t.Fatalf("Expecting an error.")
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expecting no retries, got %d.", attempts-1)
+	}
+
+	for _, err := range []error{
+		&url.Error{Op: "Get", URL: "foo://bar", Err: errors.New(`unsupported protocol scheme "foo"`)},
+		&url.Error{Op: "Get", URL: srv.URL, Err: x509.UnknownAuthorityError{}},
+	} {
+		if isTemporaryError(err) {
+			t.Fatalf("Expecting %v not to be retried.", err)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	policy := &Backoff{MinDelay: time.Second, MaxDelay: time.Second * 5}
+
+	expected := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 5}
+	for i, delay := range expected {
+		if got := policy.delay(i + 1); got != delay {
+			t.Fatalf("Attempt %d: expecting %v, got %v.", i+1, delay, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.delay(1); got < time.Millisecond*500 || got > time.Second {
+			t.Fatalf("Delay out of range: %v.", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2015, time.October, 21, 7, 28, 0, 0, time.UTC)
+
+	if wait, ok := retryAfter("120", now); !ok || wait != time.Minute*2 {
+		t.Fatalf("Expecting 2m, got %v.", wait)
+	}
+
+	if wait, ok := retryAfter("Wed, 21 Oct 2015 07:28:30 GMT", now); !ok || wait != time.Second*30 {
+		t.Fatalf("Expecting 30s, got %v.", wait)
+	}
+
+	if wait, ok := retryAfter("Wed, 21 Oct 2015 07:00:00 GMT", now); !ok || wait != 0 {
+		t.Fatalf("Expecting 0, got %v.", wait)
+	}
+
+	if _, ok := retryAfter("soon", now); ok {
+		t.Fatalf("Expecting invalid value to be ignored.")
+	}
+
+	// Servers asking for more than MaxDelay are not retried.
+	res := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"60"}}}
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, ok := (&Backoff{MaxDelay: time.Second}).Retry(1, req, res, nil); ok {
+		t.Fatalf("Expecting no retry.")
+	}
+}
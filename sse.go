@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const eventStreamMediaType = `text/event-stream`
+
+// defaultReconnectDelay is how long Subscribe waits before reconnecting when
+// the server didn't set a retry interval.
+const defaultReconnectDelay = 3 * time.Second
+
+// Event is a message received from a server-sent events stream.
+type Event struct {
+	// ID is the last event ID set by the stream, it's sent back in the
+	// Last-Event-ID header when reconnecting.
+	ID string
+	// Type is the event type, "message" when the server didn't set one.
+	Type string
+	// Data is the event payload, multiple data lines are joined with "\n".
+	Data string
+}
+
+// Subscribe connects to a server-sent events (text/event-stream) endpoint and
+// calls fn for every event received, until ctx is done or fn returns an error.
+// When the connection is lost Subscribe reconnects after the retry interval set
+// by the server (three seconds by default), sending the ID of the last event
+// received in the Last-Event-ID header.
+//
+// Only network failures and streams cut short are reconnected from, Subscribe
+// returns the error given by fn, the context's error once it's done, a
+// *StatusError for responses other than 200 OK, nil when the server asks to
+// stop with 204 No Content, and any other error, such as an invalid URL or a
+// certificate that can't be verified, as soon as it happens. The client's
+// Total timeout does not apply to subscriptions.
+func (self *Client) Subscribe(ctx context.Context, path string, data url.Values, fn func(*Event) error) error {
+	var lastID string
+
+	delay := defaultReconnectDelay
+
+	for {
+		var res *http.Response
+		var body io.ReadCloser
+
+		req := self.R().
+			QueryValues(data).
+			Header("Accept", eventStreamMediaType).
+			Header("Cache-Control", "no-cache").
+			Timeouts(Timeouts{Total: -1}).
+			Use(func(next Doer) Doer {
+				return DoerFunc(func(req *http.Request) (*http.Response, error) {
+					r, err := next.Do(req)
+					res = r
+					return r, err
+				})
+			}).
+			Into(&body)
+
+		if lastID != "" {
+			req.Header("Last-Event-ID", lastID)
+		}
+
+		err := req.Send(ctx, "GET", path)
+
+		if err == nil {
+			switch {
+			case res.StatusCode == http.StatusNoContent:
+				body.Close()
+				return nil
+			case res.StatusCode != http.StatusOK:
+				return self.newStatusError(res, body, nil)
+			case mediaType(res.Header.Get("Content-Type")) != eventStreamMediaType:
+				body.Close()
+				return fmt.Errorf(ErrNotEventStream.Error(), res.Header.Get("Content-Type"))
+			}
+
+			s := &eventStream{id: lastID, delay: delay}
+			err = s.read(body, fn)
+			body.Close()
+
+			lastID, delay = s.id, s.delay
+
+			if s.stopped {
+				return err
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil && !isTransientStreamError(err) {
+			return err
+		}
+
+		if err = sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// isTransientStreamError tells whether err is a network failure or a stream
+// that was cut short, which Subscribe reconnects from.
+func isTransientStreamError(err error) bool {
+	return isTemporaryError(err)
+}
+
+// eventStream parses a text/event-stream as described in the WHATWG HTML
+// specification.
+type eventStream struct {
+	id      string
+	delay   time.Duration
+	stopped bool
+}
+
+// read dispatches the events of r to fn until r ends or fn fails, in which
+// case stopped is set.
+func (self *eventStream) read(r io.Reader, fn func(*Event) error) error {
+	var eventType string
+	var data bytes.Buffer
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	scanner.Split(scanEventLines())
+
+	first := true
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		if line == "" {
+			if data.Len() > 0 {
+				event := &Event{
+					ID:   self.id,
+					Type: eventType,
+					Data: strings.TrimSuffix(data.String(), "\n"),
+				}
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				if err := fn(event); err != nil {
+					self.stopped = true
+					return err
+				}
+			}
+			eventType = ""
+			data.Reset()
+			continue
+		}
+
+		if line[0] == ':' {
+			// Comment, usually sent to keep the connection alive.
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				self.id = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+				self.delay = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	// An event that was not terminated by a blank line is discarded.
+	return scanner.Err()
+}
+
+// scanEventLines returns a bufio.SplitFunc that splits lines ended by CRLF, LF
+// or CR.
+func scanEventLines() bufio.SplitFunc {
+	var cr bool
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		var skip int
+
+		if cr && len(data) > 0 {
+			cr = false
+			if data[0] == '\n' {
+				// Second half of a CRLF.
+				skip = 1
+			}
+		}
+
+		if i := bytes.IndexAny(data[skip:], "\r\n"); i >= 0 {
+			cr = data[skip+i] == '\r'
+			return skip + i + 1, data[skip : skip+i], nil
+		}
+
+		if atEOF && len(data) > skip {
+			return len(data), data[skip:], nil
+		}
+
+		return skip, nil, nil
+	}
+}
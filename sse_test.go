@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	var connections int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/done":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, "{}")
+			return
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/idle":
+			w.Header().Set("Content-Type", eventStreamMediaType)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+
+		if r.Header.Get("Accept") != eventStreamMediaType {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+
+		w.Header().Set("Content-Type", eventStreamMediaType)
+
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			if r.Header.Get("Last-Event-ID") != "" {
+				t.Errorf("Unexpected Last-Event-ID on first connection.")
+			}
+			fmt.Fprint(w, "\ufeff: keep alive\n")
+			fmt.Fprint(w, "retry: 10\n")
+			fmt.Fprint(w, "data: first\n\n")
+			fmt.Fprint(w, "event: update\rid: 1\r\ndata: line 1\ndata:line 2\n\n")
+			fmt.Fprint(w, "data\n\n")
+			fmt.Fprint(w, "id: 2\ndata: incomplete\n")
+		default:
+			if id := r.Header.Get("Last-Event-ID"); id != "2" {
+				t.Errorf("Expecting Last-Event-ID 2, got %q.", id)
+			}
+			fmt.Fprint(w, "data: last\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []Event
+	stop := errors.New("stop")
+
+	err = client.Subscribe(context.Background(), "/events", nil, func(event *Event) error {
+		events = append(events, *event)
+		if event.Data == "last" {
+			return stop
+		}
+		return nil
+	})
+
+	if err != stop {
+		t.Fatalf("Expecting the error returned by the callback, got %v.", err)
+	}
+
+	expected := []Event{
+		{Type: "message", Data: "first"},
+		{ID: "1", Type: "update", Data: "line 1\nline 2"},
+		// A "data" line without a value still dispatches an event.
+		{ID: "1", Type: "message", Data: ""},
+		{ID: "2", Type: "message", Data: "last"},
+	}
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Unexpected events:\n%#v\nexpecting:\n%#v", events, expected)
+	}
+
+	if err = client.Subscribe(context.Background(), "/done", nil, nil); err != nil {
+		t.Fatalf("Expecting nil, got %v.", err)
+	}
+
+	var statusErr *StatusError
+	if err = client.Subscribe(context.Background(), "/missing", nil, nil); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting *StatusError, got %v.", err)
+	}
+
+	if err = client.Subscribe(context.Background(), "/json", nil, nil); err == nil {
+		t.Fatalf("Expecting an error for a non event-stream response.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err = client.Subscribe(ctx, "/idle", nil, nil); err != context.DeadlineExceeded {
+		t.Fatalf("Expecting context.DeadlineExceeded, got %v.", err)
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", eventStreamMediaType)
+	}))
+	defer srv.Close()
+
+	// The certificate of the test server is not trusted.
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Errors that won't go away by reconnecting are returned right away.
+	for _, path := range []string{"/%zz", "/events"} {
+		start := time.Now()
+
+		err = client.Subscribe(ctx, path, nil, func(*Event) error {
+			return nil
+		})
+
+		if err == nil || err == context.DeadlineExceeded {
+			t.Fatalf("Expecting an error for %q, got %v.", path, err)
+		}
+
+		if time.Since(start) >= defaultReconnectDelay {
+			t.Fatalf("Expecting %q to fail without reconnecting.", path)
+		}
+	}
+
+	for _, err := range []error{io.EOF, io.ErrUnexpectedEOF, &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}} {
+		if !isTransientStreamError(err) {
+			t.Fatalf("Expecting %v to be reconnected from.", err)
+		}
+	}
+
+	for _, err := range []error{ErrResponseTooLarge, fmt.Errorf(ErrUnsupportedEncoding.Error(), "x"), &url.Error{Op: "parse", Err: url.EscapeError("%zz")}} {
+		if isTransientStreamError(err) {
+			t.Fatalf("Expecting %v to be returned.", err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Request phases that can time out, see TimeoutError.
+const (
+	TimeoutTotal          = "total"
+	TimeoutDial           = "dial"
+	TimeoutTLSHandshake   = "tls handshake"
+	TimeoutResponseHeader = "response header"
+	TimeoutIdleRead       = "idle read"
+)
+
+// Timeouts limits how long the different phases of a request can take. Zero
+// values mean there's no limit.
+//
+// Dial, TLSHandshake and ResponseHeader rely on the client trace hooks of
+// net/http, they have no effect on transports that don't call them.
+type Timeouts struct {
+	// Limit for the whole request, retries and reading the response body
+	// included.
+	Total time.Duration
+	// Limit for resolving the host name and establishing a connection.
+	Dial time.Duration
+	// Limit for the TLS handshake.
+	TLSHandshake time.Duration
+	// Limit for receiving the response headers once the request was written.
+	ResponseHeader time.Duration
+	// Limit for a single read of the response body to receive some data.
+	IdleRead time.Duration
+}
+
+// override returns a copy of self with the non-zero fields of other. Negative
+// values are kept as they are and mean there's no limit.
+func (self Timeouts) override(other Timeouts) Timeouts {
+	pick := func(a, b time.Duration) time.Duration {
+		if b != 0 {
+			return b
+		}
+		return a
+	}
+	return Timeouts{
+		Total:          pick(self.Total, other.Total),
+		Dial:           pick(self.Dial, other.Dial),
+		TLSHandshake:   pick(self.TLSHandshake, other.TLSHandshake),
+		ResponseHeader: pick(self.ResponseHeader, other.ResponseHeader),
+		IdleRead:       pick(self.IdleRead, other.IdleRead),
+	}
+}
+
+func (self Timeouts) phased() bool {
+	return self.Dial > 0 || self.TLSHandshake > 0 || self.ResponseHeader > 0 || self.IdleRead > 0
+}
+
+// WithTimeouts returns a copy of ctx that makes the request use the non-zero
+// fields of timeouts instead of the ones of the client. Negative values remove
+// the client's limit.
+func WithTimeouts(ctx context.Context, timeouts Timeouts) context.Context {
+	return withOptions(ctx, func(opts *callOptions) {
+		opts.timeouts = opts.timeouts.override(timeouts)
+	})
+}
+
+// TimeoutError is returned when a request phase takes longer than allowed by
+// the client's Timeouts. It implements net.Error.
+type TimeoutError struct {
+	// One of TimeoutTotal, TimeoutDial, TimeoutTLSHandshake,
+	// TimeoutResponseHeader or TimeoutIdleRead.
+	Phase string
+	Limit time.Duration
+}
+
+func (self *TimeoutError) Error() string {
+	return fmt.Sprintf(`Timed out after %v (%s timeout).`, self.Limit, self.Phase)
+}
+
+// Timeout is always true.
+func (self *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary is always true.
+func (self *TimeoutError) Temporary() bool {
+	return true
+}
+
+// timeoutCause replaces err with the *TimeoutError that made ctx be
+// cancelled, if any.
+func timeoutCause(ctx context.Context, err error) error {
+	var timeoutErr *TimeoutError
+
+	if err == nil || !errors.As(context.Cause(ctx), &timeoutErr) {
+		return err
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{Op: urlErr.Op, URL: urlErr.URL, Err: timeoutErr}
+	}
+
+	return timeoutErr
+}
+
+// watchdog cancels a request when one of its phases takes too long.
+type watchdog struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+	timers map[string]*time.Timer
+}
+
+func (self *watchdog) start(phase string, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if _, ok := self.timers[phase]; ok {
+		return
+	}
+
+	self.timers[phase] = time.AfterFunc(timeout, func() {
+		self.cancel(&TimeoutError{Phase: phase, Limit: timeout})
+	})
+}
+
+func (self *watchdog) stop(phase string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	timer, ok := self.timers[phase]
+
+	if !ok {
+		// Keeps the phase from being started later.
+		self.timers[phase] = nil
+	} else if timer != nil {
+		timer.Stop()
+	}
+}
+
+func (self *watchdog) stopAll() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for phase, timer := range self.timers {
+		if timer != nil {
+			timer.Stop()
+		}
+		self.timers[phase] = nil
+	}
+}
+
+func (self *watchdog) trace(timeouts Timeouts) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			self.start(TimeoutDial, timeouts.Dial)
+		},
+		ConnectStart: func(string, string) {
+			self.start(TimeoutDial, timeouts.Dial)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				self.stop(TimeoutDial)
+			}
+		},
+		TLSHandshakeStart: func() {
+			self.start(TimeoutTLSHandshake, timeouts.TLSHandshake)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			self.stop(TimeoutTLSHandshake)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			self.start(TimeoutResponseHeader, timeouts.ResponseHeader)
+		},
+		GotFirstResponseByte: func() {
+			self.stop(TimeoutResponseHeader)
+		},
+	}
+}
+
+// attempt sends req once, enforcing the per phase timeouts.
+func (self *Client) attempt(client *http.Client, req *http.Request, timeouts Timeouts) (*http.Response, error) {
+	if !timeouts.phased() {
+		res, err := client.Do(req)
+		return res, timeoutCause(req.Context(), err)
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+
+	dog := &watchdog{cancel: cancel, timers: map[string]*time.Timer{}}
+
+	res, err := client.Do(req.WithContext(httptrace.WithClientTrace(ctx, dog.trace(timeouts))))
+
+	dog.stopAll()
+
+	if err != nil {
+		err = timeoutCause(ctx, err)
+		cancel(nil)
+		return nil, err
+	}
+
+	res.Body = &timeoutBody{
+		ReadCloser: res.Body,
+		ctx:        ctx,
+		cancel:     cancel,
+		idle:       timeouts.IdleRead,
+	}
+
+	return res, nil
+}
+
+// timeoutBody enforces the idle read timeout and releases the context of the
+// request when closed or fully read.
+type timeoutBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	idle   time.Duration
+}
+
+func (self *timeoutBody) Read(p []byte) (int, error) {
+	var timer *time.Timer
+
+	if self.idle > 0 {
+		idle := self.idle
+		timer = time.AfterFunc(idle, func() {
+			self.cancel(&TimeoutError{Phase: TimeoutIdleRead, Limit: idle})
+		})
+	}
+
+	n, err := self.ReadCloser.Read(p)
+
+	if timer != nil {
+		timer.Stop()
+	}
+
+	if err == io.EOF {
+		self.cancel(nil)
+	} else if err != nil {
+		err = timeoutCause(self.ctx, err)
+	}
+
+	return n, err
+}
+
+func (self *timeoutBody) Close() error {
+	err := self.ReadCloser.Close()
+	self.cancel(nil)
+	return err
+}
+
+// cancelBody releases a context when the body is closed or fully read.
+type cancelBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (self *cancelBody) Read(p []byte) (int, error) {
+	n, err := self.ReadCloser.Read(p)
+
+	if err == io.EOF {
+		self.cancel()
+	} else if err != nil {
+		err = timeoutCause(self.ctx, err)
+	}
+
+	return n, err
+}
+
+func (self *cancelBody) Close() error {
+	err := self.ReadCloser.Close()
+	self.cancel()
+	return err
+}
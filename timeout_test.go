@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeouts(t *testing.T) {
+	var err error
+
+	release := make(chan struct{})
+	defer close(release)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow-header":
+			select {
+			case <-time.After(time.Millisecond * 200):
+			case <-release:
+			}
+		case "/slow-body":
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(time.Millisecond * 200):
+			case <-release:
+			}
+		}
+		w.Write([]byte("done"))
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.Timeouts = Timeouts{
+		ResponseHeader: time.Millisecond * 50,
+		IdleRead:       time.Millisecond * 50,
+	}
+
+	expectTimeout := func(err error, phase string) {
+		var timeoutErr *TimeoutError
+		if !errors.As(err, &timeoutErr) {
+			t.Fatalf("Expecting *TimeoutError, got %v.", err)
+		}
+		if timeoutErr.Phase != phase {
+			t.Fatalf("Expecting %s timeout, got %s.", phase, timeoutErr.Phase)
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("Expecting a net.Error timeout.")
+		}
+	}
+
+	var buf string
+
+	if err = client.Get(&buf, "/fast", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf != "done" {
+		t.Fatalf("Unexpected response %q.", buf)
+	}
+
+	expectTimeout(client.Get(&buf, "/slow-header", nil), TimeoutResponseHeader)
+
+	expectTimeout(client.Get(&buf, "/slow-body", nil), TimeoutIdleRead)
+
+	// Per call timeouts.
+	ctx := WithTimeouts(context.Background(), Timeouts{ResponseHeader: -1, IdleRead: -1, Total: time.Millisecond * 100})
+
+	expectTimeout(client.GetContext(ctx, &buf, "/slow-header", nil), TimeoutTotal)
+
+	ctx = WithTimeouts(context.Background(), Timeouts{ResponseHeader: time.Second, IdleRead: time.Second})
+
+	if err = client.GetContext(ctx, &buf, "/slow-body", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSHandshakeTimeout(t *testing.T) {
+	var err error
+
+	// Accepts connections but never talks back.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, err := New("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.Timeouts.TLSHandshake = time.Millisecond * 50
+
+	err = client.Get(nil, "/", nil)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Phase != TimeoutTLSHandshake {
+		t.Fatalf("Expecting TLS handshake timeout, got %v.", err)
+	}
+
+	// A refused connection is not a timeout.
+	ln.Close()
+
+	if err = client.Get(nil, "/", nil); err == nil || errors.As(err, &timeoutErr) {
+		t.Fatalf("Expecting a non timeout error, got %v.", err)
+	}
+}
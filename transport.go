@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	sharedTransport     *http.Transport
+	sharedTransportOnce sync.Once
+)
+
+// newTransport returns a transport based on http.DefaultTransport with
+// settings better suited for clients that talk to a few hosts a lot.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 16
+	transport.IdleConnTimeout = time.Second * 90
+
+	return transport
+}
+
+// defaultTransport returns the transport shared by all clients that don't
+// have a transport of their own.
+func defaultTransport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = newTransport()
+	})
+	return sharedTransport
+}
+
+// roundTripper returns the round tripper requests should be sent through.
+func (self *Client) roundTripper() (http.RoundTripper, error) {
+	if self.TlsTransport != nil {
+		return self.TlsTransport, nil
+	}
+
+	base := self.Transport
+
+	if self.TLSConfig == nil {
+		if base != nil {
+			return base, nil
+		}
+		return defaultTransport(), nil
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// The layered transport is kept as long as the fields it derives from
+	// don't change, so its connections can be reused.
+	if self.tlsTransport != nil && self.tlsBase == base && self.tlsConfig == self.TLSConfig {
+		return self.tlsTransport, nil
+	}
+
+	var transport *http.Transport
+
+	switch t := base.(type) {
+	case nil:
+		transport = newTransport()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		// There's no way to layer a TLS configuration onto an arbitrary
+		// round tripper, sending without it would be less secure than
+		// asked for.
+		return nil, ErrTLSConfigNotApplicable
+	}
+
+	transport.TLSClientConfig = self.TLSConfig.Clone()
+
+	self.tlsTransport = transport
+	self.tlsBase = base
+	self.tlsConfig = self.TLSConfig
+
+	return transport, nil
+}
+
+// httpClient returns the *http.Client requests should be sent with.
+func (self *Client) httpClient() (*http.Client, error) {
+	if self.HTTPClient != nil {
+		if self.TLSConfig != nil && self.TlsTransport == nil {
+			return nil, ErrTLSConfigNotApplicable
+		}
+		return self.HTTPClient, nil
+	}
+
+	transport, err := self.roundTripper()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+	}
+
+	// Adding cookie jar
+	if self.CookieJar != nil {
+		client.Jar = self.CookieJar
+	}
+
+	return client, nil
+}
@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type countingTransport struct {
+	http.RoundTripper
+	count int32
+}
+
+func (self *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&self.count, 1)
+	return self.RoundTripper.RoundTrip(req)
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var err error
+	var conns int32
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		var buf []byte
+		if err = client.Get(&buf, "/", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if conns != 1 {
+		t.Fatalf("Expecting a single connection, got %d.", conns)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var err error
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	client, err := NewTLS(srv.URL, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The TLS configuration is layered onto the given transport.
+	transport := &countingTransport{}
+	base := newTransport()
+	client.Transport = base
+
+	var buf string
+	if err = client.Get(&buf, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf != "secure" {
+		t.Fatalf("Unexpected response %q.", buf)
+	}
+
+	if base.TLSClientConfig != nil && base.TLSClientConfig.RootCAs != nil {
+		t.Fatalf("The base transport must not be modified.")
+	}
+
+	first, err := client.roundTripper()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second, _ := client.roundTripper(); first != second {
+		t.Fatalf("Expecting the layered transport to be reused.")
+	}
+
+	// The TLS configuration can't be layered onto arbitrary round trippers,
+	// the request must fail instead of being sent without it.
+	transport.RoundTripper = first
+	client.Transport = transport
+
+	if err = client.Get(&buf, "/", nil); err != ErrTLSConfigNotApplicable {
+		t.Fatalf("Expecting ErrTLSConfigNotApplicable, got %v.", err)
+	}
+
+	if transport.count != 0 {
+		t.Fatalf("Expecting no request to be sent.")
+	}
+
+	// Same for a client that brings its own *http.Client.
+	client.Transport = nil
+	client.HTTPClient = &http.Client{Transport: first}
+
+	if err = client.Get(&buf, "/", nil); err != ErrTLSConfigNotApplicable {
+		t.Fatalf("Expecting ErrTLSConfigNotApplicable, got %v.", err)
+	}
+
+	client.HTTPClient = nil
+
+	// Arbitrary round trippers are used as given.
+	client.Transport = transport
+	client.TLSConfig = nil
+
+	if err = client.Get(&buf, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if transport.count != 1 {
+		t.Fatalf("Expecting request to go through the custom transport.")
+	}
+}
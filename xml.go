@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strings"
+)
+
+// XMLDecoder is the Decoder used by default for XML responses
+// (application/xml, text/xml and any +xml type).
+//
+// Documents are converted into UTF-8 according to the charset parameter of
+// the Content-Type header or, when there's none, to the encoding given by
+// their XML declaration. Charsets are looked up among the ones added with
+// RegisterCharset.
+type XMLDecoder struct {
+	// Overrides the charset lookup when set.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+}
+
+// Decode implements Decoder.
+func (self XMLDecoder) Decode(r io.Reader, dst interface{}) error {
+	return self.DecodeContentType(r, "", dst)
+}
+
+// DecodeContentType implements ContentTypeDecoder.
+func (self XMLDecoder) DecodeContentType(r io.Reader, contentType string, dst interface{}) error {
+	var err error
+
+	toUTF8 := self.CharsetReader
+	if toUTF8 == nil {
+		toUTF8 = charsetReader
+	}
+
+	_, params, _ := mime.ParseMediaType(contentType)
+
+	if charset := params["charset"]; charset != "" {
+		// The charset given by the header takes precedence over the XML
+		// declaration.
+		if r, err = toUTF8(charset, r); err != nil {
+			return err
+		}
+		toUTF8 = func(_ string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+	}
+
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = toUTF8
+
+	return dec.Decode(dst)
+}
+
+// newXMLBody returns a body with the XML encoding of v in the given charset.
+func newXMLBody(v interface{}, charset string) (*requestBody, error) {
+	buf, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBufferString(`<?xml version="1.0" encoding="` + charset + `"?>` + "\n")
+
+	w := encoding.NewEncoder(out)
+
+	if _, err = w.Write(buf); err != nil {
+		return nil, err
+	}
+
+	if closer, ok := w.(io.Closer); ok {
+		if err = closer.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return newBytesBody("application/xml; charset="+strings.ToLower(charset), out.Bytes()), nil
+}
+
+// PostXML performs a HTTP POST request with the XML encoding of body and, when
+// complete, attempts to convert the response body into the datatype given by
+// dst (a pointer to a struct, map or []byte array).
+func (self *Client) PostXML(dst interface{}, path string, body interface{}) error {
+	return self.PostXMLContext(context.Background(), dst, path, body)
+}
+
+// PostXMLContext is like PostXML but the request is bound to the given
+// context.
+func (self *Client) PostXMLContext(ctx context.Context, dst interface{}, path string, body interface{}) error {
+	return self.R().XML(body).Into(dst).Post(ctx, path)
+}
+
+// PutXML performs a HTTP PUT request with the XML encoding of body and, when
+// complete, attempts to convert the response body into the datatype given by
+// dst (a pointer to a struct, map or []byte array).
+func (self *Client) PutXML(dst interface{}, path string, body interface{}) error {
+	return self.PutXMLContext(context.Background(), dst, path, body)
+}
+
+// PutXMLContext is like PutXML but the request is bound to the given context.
+func (self *Client) PutXMLContext(ctx context.Context, dst interface{}, path string, body interface{}) error {
+	return self.R().XML(body).Into(dst).Put(ctx, path)
+}
+
+// PostXML performs a HTTP POST request with the XML encoding of body using the
+// default client.
+func PostXML(dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PostXML(dest, uri, body)
+}
+
+// PostXMLContext is like PostXML but the request is bound to the given
+// context.
+func PostXMLContext(ctx context.Context, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PostXMLContext(ctx, dest, uri, body)
+}
+
+// PutXML performs a HTTP PUT request with the XML encoding of body using the
+// default client.
+func PutXML(dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PutXML(dest, uri, body)
+}
+
+// PutXMLContext is like PutXML but the request is bound to the given context.
+func PutXMLContext(ctx context.Context, dest interface{}, uri string, body interface{}) error {
+	return DefaultClient.PutXMLContext(ctx, dest, uri, body)
+}
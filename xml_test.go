@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type xmlPerson struct {
+	XMLName xml.Name `xml:"person"`
+	Name    string   `xml:"name"`
+	City    string   `xml:"city"`
+}
+
+func TestXMLResponse(t *testing.T) {
+	var err error
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/declared":
+			// Latin-1 encoded, announced by the XML declaration only.
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><person><name>Jos\xe9</name><city>M\xe9rida</city></person>"))
+		case "/header":
+			// The header takes precedence over the declaration.
+			w.Header().Set("Content-Type", "application/xml; charset=iso-8859-1")
+			w.Write([]byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?><person><name>Jos\xe9</name></person>"))
+		case "/suffix":
+			w.Header().Set("Content-Type", "application/atom+xml")
+			w.Write([]byte(`<person><name>Gopher</name></person>`))
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			w.Write(body)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p xmlPerson
+
+	if err = client.Get(&p, "/declared", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "José" || p.City != "Mérida" {
+		t.Fatalf("Unexpected value %#v.", p)
+	}
+
+	p = xmlPerson{}
+	if err = client.Get(&p, "/header", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "José" {
+		t.Fatalf("Unexpected value %#v.", p)
+	}
+
+	p = xmlPerson{}
+	if err = client.Get(&p, "/suffix", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "Gopher" {
+		t.Fatalf("Unexpected value %#v.", p)
+	}
+
+	// Encoding.
+	in := xmlPerson{Name: "José", City: "Mérida"}
+
+	var res Response
+	if err = client.PostXML(&res, "/echo", in); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Header.Get("Content-Type") != "application/xml; charset=utf-8" || !strings.Contains(string(res.Body), "<name>José</name>") {
+		t.Fatalf("Unexpected body %q (%s).", res.Body, res.Header.Get("Content-Type"))
+	}
+
+	if err = client.R().XMLCharset(in, "ISO-8859-1").Into(&res).Put(context.Background(), "/echo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Header.Get("Content-Type") != "application/xml; charset=iso-8859-1" || !strings.Contains(string(res.Body), "<name>Jos\xe9</name>") {
+		t.Fatalf("Unexpected body %q (%s).", res.Body, res.Header.Get("Content-Type"))
+	}
+
+	p = xmlPerson{}
+	if err = client.R().XMLCharset(in, "ISO-8859-1").Into(&p).Put(context.Background(), "/echo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != in.Name || p.City != in.City {
+		t.Fatalf("Expecting %#v, got %#v.", in, p)
+	}
+
+	if err = client.R().XMLCharset(in, "US-ASCII").Put(context.Background(), "/echo"); err == nil {
+		t.Fatalf("Expecting encoding error.")
+	}
+
+	if err = client.R().XMLCharset(in, "klingon").Put(context.Background(), "/echo"); err == nil {
+		t.Fatalf("Expecting unknown charset error.")
+	}
+}